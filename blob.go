@@ -0,0 +1,68 @@
+package magicsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+)
+
+// Blob is a field type for large binary columns which shouldn't be copied
+// around any more than necessary.  When a Blob is scanned, the column's bytes
+// are written straight to Writer from the driver's buffer, so they're never
+// copied into a []byte.  When a Blob is inserted or updated, its data is read
+// from Reader.  Blob implements io.Reader itself, so drivers which accept an
+// io.Reader argument can stream it; for all others, Reader is read into
+// memory when the driver asks for the value.
+//
+// A Blob with a nil Reader is stored as NULL.  Scanning into a Blob without a
+// Writer discards the data and only sets Valid, so structures with Blob
+// fields can still be read by AllObjects and the like when the data isn't
+// wanted.
+type Blob struct {
+	Reader io.Reader
+	Writer io.Writer
+
+	// Valid is set by Scan: false if the column was NULL
+	Valid bool
+}
+
+// Read implements io.Reader by reading from b.Reader
+func (b Blob) Read(p []byte) (int, error) {
+	if b.Reader == nil {
+		return 0, io.EOF
+	}
+	return b.Reader.Read(p)
+}
+
+// Value implements driver.Valuer, reading all of b.Reader
+func (b Blob) Value() (driver.Value, error) {
+	if b.Reader == nil {
+		return nil, nil
+	}
+	return io.ReadAll(b.Reader)
+}
+
+// Scan implements sql.Scanner, writing src to b.Writer, or discarding it if
+// b.Writer is nil
+func (b *Blob) Scan(src interface{}) error {
+	var w = b.Writer
+	if w == nil {
+		w = io.Discard
+	}
+
+	var err error
+	switch v := src.(type) {
+	case nil:
+		b.Valid = false
+		return nil
+	case []byte:
+		_, err = w.Write(v)
+	case string:
+		_, err = io.WriteString(w, v)
+	default:
+		return fmt.Errorf("magicsql: can't scan %T into a Blob", src)
+	}
+
+	b.Valid = err == nil
+	return err
+}
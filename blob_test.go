@@ -0,0 +1,170 @@
+package magicsql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+type BlobFoo struct {
+	ID    int `sql:",primary"`
+	Data  []byte
+	Meta  json.RawMessage
+	Large Blob
+}
+
+func getdbBlob() *DB {
+	var db, err = Open("sqlite3", "./test.db")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.DataSource().Exec(`
+		drop table if exists blob_foos;
+		create table blob_foos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			data blob,
+			meta text,
+			large blob
+		);
+	`)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+func TestByteFields(t *testing.T) {
+	var db = getdbBlob()
+	var op = db.Operation()
+	op.Save("blob_foos", &BlobFoo{Data: []byte{0, 1, 2}, Meta: json.RawMessage(`{"a":1}`)})
+	op.Save("blob_foos", &BlobFoo{Data: []byte{}})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var n int
+	db.DataSource().QueryRow("SELECT COUNT(*) FROM blob_foos WHERE meta IS NULL AND large IS NULL").Scan(&n)
+	assert.Equal(1, n, "Nil byte slices and Blobs without a Reader are stored as NULL", t)
+
+	var foos []*BlobFoo
+	op.Select("blob_foos", &BlobFoo{}).Order("id").AllObjects(&foos)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	assert.True(bytes.Equal([]byte{0, 1, 2}, foos[0].Data), "Bytes are read", t)
+	assert.Equal(`{"a":1}`, string(foos[0].Meta), "json.RawMessage is read", t)
+	assert.True(foos[1].Data != nil && len(foos[1].Data) == 0, "Empty isn't NULL", t)
+	assert.True(foos[1].Meta == nil, "NULL is a nil slice", t)
+	assert.False(foos[1].Large.Valid, "NULL Blob isn't valid", t)
+}
+
+// copyTracker is a Writer which records each write, so a test can tell how
+// the data arrived
+type copyTracker struct {
+	writes [][]byte
+}
+
+func (c *copyTracker) Write(p []byte) (int, error) {
+	c.writes = append(c.writes, p)
+	return len(p), nil
+}
+
+func TestBlobStreaming(t *testing.T) {
+	var db = getdbBlob()
+	var op = db.Operation()
+
+	var payload = strings.Repeat("0123456789", 100000)
+	op.Save("blob_foos", &BlobFoo{Large: Blob{Reader: strings.NewReader(payload)}})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var w = &copyTracker{}
+	var foo = &BlobFoo{Large: Blob{Writer: w}}
+	op.Select("blob_foos", foo).First(foo)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.True(foo.Large.Valid, "Blob is valid", t)
+	assert.Equal(1, len(w.writes), "Blob was written in one piece", t)
+	assert.Equal(payload, string(w.writes[0]), "Blob data was written to the Writer", t)
+
+	foo = &BlobFoo{}
+	op.Select("blob_foos", foo).First(foo)
+	assert.True(op.Err() == nil, fmt.Sprintf("Scanning without a Writer (%s) isn't an error", op.Err()), t)
+	assert.True(foo.Large.Valid, "Blob without a Writer still records validity", t)
+}
+
+func TestBlobLogging(t *testing.T) {
+	var db = getdbBlob()
+	var rec = db.Record()
+	var op = db.Operation()
+	op.Dbg = true
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	op.Save("blob_foos", &BlobFoo{Large: Blob{Reader: strings.NewReader("hello")}})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var n int
+	db.DataSource().QueryRow("SELECT length(large) FROM blob_foos").Scan(&n)
+	assert.Equal(5, n, "Logging doesn't read the Blob's data", t)
+
+	var args = rec.Statements()[0].Args
+	assert.Equal("<blob>", args[len(args)-1], "Blob is recorded as a placeholder", t)
+	assert.Equal("NULL", stringify(&Blob{}), "Blob without a Reader is NULL", t)
+}
+
+// streamConn is a fake connection for a driver which accepts io.Reader
+// arguments and reads them itself, recording what it was given
+type streamConn struct {
+	argTypes []string
+	read     []byte
+}
+
+func (c *streamConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *streamConn) Driver() driver.Driver                        { return nil }
+func (c *streamConn) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("no prepare") }
+func (c *streamConn) Close() error                                 { return nil }
+func (c *streamConn) Begin() (driver.Tx, error)                    { return nil, errors.New("no transactions") }
+
+// CheckNamedValue accepts readers as-is, leaving everything else to the
+// default conversion
+func (c *streamConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.(io.Reader); ok {
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func (c *streamConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	for _, arg := range args {
+		c.argTypes = append(c.argTypes, fmt.Sprintf("%T", arg.Value))
+		if r, ok := arg.Value.(io.Reader); ok {
+			var data, err = io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			c.read = data
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func TestBlobStreamingDriver(t *testing.T) {
+	var conn = &streamConn{}
+	var db = Wrap(sql.OpenDB(conn))
+	var op = db.Operation()
+
+	var payload = strings.Repeat("0123456789", 1000)
+	op.Table("blob_foos", &BlobFoo{}).Insert(&BlobFoo{Large: Blob{Reader: strings.NewReader(payload)}})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("magicsql.Blob", conn.argTypes[2], "Driver was handed the Blob itself", t)
+	assert.Equal(payload, string(conn.read), "Driver read the whole Reader", t)
+}
@@ -0,0 +1,296 @@
+package magicsql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Sentinel errors for common classes of database failure.  These are never
+// returned directly; instead, errors.Is matches them against an Operation's
+// error when the underlying driver error belongs to that class.
+var (
+	ErrUniqueViolation      = errors.New("unique constraint violation")
+	ErrForeignKeyViolation  = errors.New("foreign key constraint violation")
+	ErrNotNullViolation     = errors.New("not-null constraint violation")
+	ErrDeadlock             = errors.New("deadlock")
+	ErrSerializationFailure = errors.New("serialization failure")
+	ErrBusy                 = errors.New("database busy or locked")
+	ErrTimeout              = errors.New("timeout")
+)
+
+// Classification describes a driver error in driver-agnostic terms.  Kind is
+// one of the sentinel errors above (or a caller-defined sentinel).  The
+// remaining fields are filled in when the driver supplies them.
+type Classification struct {
+	Kind       error
+	Constraint string
+	Table      string
+	Column     string
+}
+
+// Classifier inspects a driver error and returns its classification, or nil
+// if it doesn't recognize the error
+type Classifier func(err error) *Classification
+
+var classifiers struct {
+	sync.RWMutex
+	custom []*Classifier
+}
+
+// builtinClassifiers run after any registered classifiers, in order
+var builtinClassifiers = []Classifier{
+	classifyContext,
+	classifySQLState,
+	classifyMySQL,
+	classifySQLite,
+	classifyMessage,
+}
+
+// RegisterClassifier adds a classifier for a driver's errors.  Registered
+// classifiers are tried in the order they were registered, before the
+// built-in classifiers for SQLite, MySQL, and PostgreSQL.  Calling the
+// returned function removes the classifier again.
+func RegisterClassifier(c Classifier) (unregister func()) {
+	var entry = &c
+	classifiers.Lock()
+	classifiers.custom = append(classifiers.custom[:len(classifiers.custom):len(classifiers.custom)], entry)
+	classifiers.Unlock()
+
+	return func() {
+		classifiers.Lock()
+		defer classifiers.Unlock()
+		var kept = make([]*Classifier, 0, len(classifiers.custom))
+		for _, e := range classifiers.custom {
+			if e != entry {
+				kept = append(kept, e)
+			}
+		}
+		classifiers.custom = kept
+	}
+}
+
+// Classify returns the classification of err, or nil if no classifier
+// recognizes it.  If err is (or wraps) an OpError, its stored classification
+// is used.
+func Classify(err error) *Classification {
+	if err == nil {
+		return nil
+	}
+
+	var opErr *OpError
+	if errors.As(err, &opErr) {
+		if opErr.Kind == nil {
+			return nil
+		}
+		return &Classification{opErr.Kind, opErr.Constraint, opErr.Table, opErr.Column}
+	}
+
+	classifiers.RLock()
+	var custom = classifiers.custom
+	classifiers.RUnlock()
+
+	for _, c := range custom {
+		if cl := (*c)(err); cl != nil {
+			return cl
+		}
+	}
+	for _, c := range builtinClassifiers {
+		if cl := c(err); cl != nil {
+			return cl
+		}
+	}
+	return nil
+}
+
+// errField looks through err's chain for a struct with the named field,
+// returning the field's value from the first error which has it
+func errField(err error, name string) (reflect.Value, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		var rv = reflect.ValueOf(err)
+		for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+			if rv.IsNil() {
+				break
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			continue
+		}
+		if f := rv.FieldByName(name); f.IsValid() {
+			return f, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// errString returns the first of the named fields found in err's chain as a
+// string, or "" if none exist
+func errString(err error, names ...string) string {
+	for _, name := range names {
+		if f, ok := errField(err, name); ok {
+			switch f.Kind() {
+			case reflect.String:
+				return f.String()
+			case reflect.Array:
+				var b = make([]byte, f.Len())
+				for i := range b {
+					b[i] = byte(f.Index(i).Uint())
+				}
+				return string(b)
+			}
+		}
+	}
+	return ""
+}
+
+func classifyContext(err error) *Classification {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Classification{Kind: ErrTimeout}
+	}
+	return nil
+}
+
+// sqlStates maps standard SQLSTATE codes to sentinel errors
+var sqlStates = map[string]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23502": ErrNotNullViolation,
+	"40001": ErrSerializationFailure,
+	"40P01": ErrDeadlock,
+	"55P03": ErrBusy,
+	"57014": ErrTimeout,
+}
+
+// classifySQLState handles drivers which expose an SQLSTATE code, such as
+// pgx (SQLState method, ConstraintName and ColumnName fields) and lib/pq (Code,
+// Constraint, and Column fields)
+func classifySQLState(err error) *Classification {
+	var state string
+	var st interface{ SQLState() string }
+	if errors.As(err, &st) {
+		state = st.SQLState()
+	} else if f, ok := errField(err, "Code"); ok && f.Kind() == reflect.String {
+		state = f.String()
+	}
+
+	var kind, ok = sqlStates[state]
+	if !ok {
+		return nil
+	}
+	return &Classification{
+		Kind:       kind,
+		Constraint: errString(err, "ConstraintName", "Constraint"),
+		Table:      errString(err, "TableName", "Table"),
+		Column:     errString(err, "ColumnName", "Column"),
+	}
+}
+
+// mysqlNumbers maps MySQL error numbers to sentinel errors
+var mysqlNumbers = map[uint64]error{
+	1062: ErrUniqueViolation,
+	1451: ErrForeignKeyViolation,
+	1452: ErrForeignKeyViolation,
+	1048: ErrNotNullViolation,
+	1364: ErrNotNullViolation,
+	1213: ErrDeadlock,
+	1205: ErrBusy,
+	3024: ErrTimeout,
+}
+
+var mysqlDupKey = regexp.MustCompile(`for key '([^']+)'`)
+var mysqlColumn = regexp.MustCompile(`Field '([^']+)'|Column '([^']+)'`)
+var mysqlFKConstraint = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+
+// classifyMySQL handles go-sql-driver/mysql's MySQLError, which has a Number
+// field and puts the interesting names in its message
+func classifyMySQL(err error) *Classification {
+	var f, ok = errField(err, "Number")
+	if !ok || f.Kind() != reflect.Uint16 {
+		return nil
+	}
+	kind, ok := mysqlNumbers[f.Uint()]
+	if !ok {
+		return nil
+	}
+
+	var c = &Classification{Kind: kind}
+	var msg = err.Error()
+	switch kind {
+	case ErrUniqueViolation:
+		if m := mysqlDupKey.FindStringSubmatch(msg); m != nil {
+			c.Constraint = m[1]
+		}
+	case ErrForeignKeyViolation:
+		if m := mysqlFKConstraint.FindStringSubmatch(msg); m != nil {
+			c.Constraint = m[1]
+		}
+	case ErrNotNullViolation:
+		if m := mysqlColumn.FindStringSubmatch(msg); m != nil {
+			c.Column = m[1] + m[2]
+		}
+	}
+	return c
+}
+
+var sqliteConstraint = regexp.MustCompile(`(UNIQUE|PRIMARY KEY|NOT NULL) constraint failed: (.+)`)
+
+// classifySQLite handles mattn/go-sqlite3 (and other SQLite drivers), whose
+// constraint messages are stable across versions
+func classifySQLite(err error) *Classification {
+	var msg = err.Error()
+	if strings.Contains(msg, "FOREIGN KEY constraint failed") {
+		return &Classification{Kind: ErrForeignKeyViolation}
+	}
+
+	var m = sqliteConstraint.FindStringSubmatch(msg)
+	if m == nil {
+		return nil
+	}
+
+	var c = &Classification{Kind: ErrUniqueViolation}
+	if m[1] == "NOT NULL" {
+		c.Kind = ErrNotNullViolation
+	}
+
+	// SQLite reports "table.column", comma-separated for multi-column keys
+	var cols = strings.Split(m[2], ", ")
+	for i, col := range cols {
+		var parts = strings.SplitN(col, ".", 2)
+		if len(parts) == 2 {
+			c.Table = parts[0]
+			cols[i] = parts[1]
+		}
+	}
+	c.Column = strings.Join(cols, ",")
+	return c
+}
+
+// retryMessages holds lowercased fragments of driver error messages for
+// drivers which don't expose codes we can use
+var retryMessages = []struct {
+	fragment string
+	kind     error
+}{
+	{"deadlock", ErrDeadlock},
+	{"database is locked", ErrBusy},
+	{"database table is locked", ErrBusy},
+	{"sqlite_busy", ErrBusy},
+	{"lock wait timeout exceeded", ErrBusy},
+	{"could not serialize access", ErrSerializationFailure},
+	{"serialization failure", ErrSerializationFailure},
+}
+
+// classifyMessage is the last resort, matching well-known message fragments
+func classifyMessage(err error) *Classification {
+	var msg = strings.ToLower(err.Error())
+	for _, rm := range retryMessages {
+		if strings.Contains(msg, rm.fragment) {
+			return &Classification{Kind: rm.kind}
+		}
+	}
+	return nil
+}
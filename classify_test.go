@@ -0,0 +1,92 @@
+package magicsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+// fakePQError mimics lib/pq's error fields
+type fakePQError struct {
+	Code       string
+	Constraint string
+	Column     string
+}
+
+func (e *fakePQError) Error() string { return "pq: " + e.Code }
+
+// fakeMySQLError mimics go-sql-driver/mysql's error fields
+type fakeMySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *fakeMySQLError) Error() string { return fmt.Sprintf("Error %d: %s", e.Number, e.Message) }
+
+func TestClassifySQLite(t *testing.T) {
+	var db = getdb()
+	db.DataSource().Exec("CREATE TABLE IF NOT EXISTS notnulls (name TEXT NOT NULL)")
+
+	var op = db.Operation()
+	op.Exec("INSERT INTO foos (one, two) VALUES ('a', 1)")
+	op.Exec("INSERT INTO foos (one, two) VALUES ('b', 1)")
+	assert.True(errors.Is(op.Err(), ErrUniqueViolation), "Duplicate primary key is a unique violation", t)
+	assert.False(errors.Is(op.Err(), ErrNotNullViolation), "Duplicate primary key isn't a not-null violation", t)
+
+	var opErr *OpError
+	errors.As(op.Err(), &opErr)
+	assert.Equal("foos", opErr.Table, "Table name from SQLite", t)
+	assert.Equal("two", opErr.Column, "Column name from SQLite", t)
+
+	op = db.Operation()
+	op.Exec("INSERT INTO notnulls (name) VALUES (NULL)")
+	assert.True(errors.Is(op.Err(), ErrNotNullViolation), "NULL in a NOT NULL column", t)
+	assert.Equal("name", Classify(op.Err()).Column, "Classify reads an OpError's classification", t)
+}
+
+func TestClassifyDrivers(t *testing.T) {
+	var c = Classify(&fakePQError{Code: "23505", Constraint: "users_email_key"})
+	assert.Equal(ErrUniqueViolation, c.Kind, "PostgreSQL unique violation", t)
+	assert.Equal("users_email_key", c.Constraint, "PostgreSQL constraint name", t)
+
+	c = Classify(fmt.Errorf("wrapped: %w", &fakePQError{Code: "23502", Column: "email"}))
+	assert.Equal(ErrNotNullViolation, c.Kind, "Wrapped PostgreSQL not-null violation", t)
+	assert.Equal("email", c.Column, "PostgreSQL column name", t)
+
+	c = Classify(&fakeMySQLError{1062, "Duplicate entry 'x' for key 'users.email'"})
+	assert.Equal(ErrUniqueViolation, c.Kind, "MySQL unique violation", t)
+	assert.Equal("users.email", c.Constraint, "MySQL key name", t)
+
+	c = Classify(&fakeMySQLError{1048, "Column 'name' cannot be null"})
+	assert.Equal(ErrNotNullViolation, c.Kind, "MySQL not-null violation", t)
+	assert.Equal("name", c.Column, "MySQL column name", t)
+
+	assert.Equal(ErrDeadlock, Classify(&fakeMySQLError{1213, "Deadlock found"}).Kind, "MySQL deadlock", t)
+	assert.Equal(ErrTimeout, Classify(context.DeadlineExceeded).Kind, "Context deadline is a timeout", t)
+	assert.True(Classify(errors.New("something else")) == nil, "Unknown errors aren't classified", t)
+	assert.True(IsRetryable(&fakePQError{Code: "40001"}), "Serialization failures are retryable", t)
+	assert.False(IsRetryable(&fakePQError{Code: "23505"}), "Unique violations aren't retryable", t)
+}
+
+func TestRegisterClassifier(t *testing.T) {
+	var errQuota = errors.New("quota exceeded")
+	var unregister = RegisterClassifier(func(err error) *Classification {
+		if err.Error() == "E42: quota" {
+			return &Classification{Kind: errQuota}
+		}
+		return nil
+	})
+	defer unregister()
+
+	var op = &Operation{}
+	op.setErr(PhaseExec, "INSERT", nil, errors.New("E42: quota"))
+	assert.True(errors.Is(op.Err(), errQuota), "Custom classifier's sentinel matches", t)
+
+	unregister()
+	assert.True(Classify(errors.New("E42: quota")) == nil, "Unregistered classifier isn't used", t)
+	unregister()
+	assert.Equal(0, len(classifiers.custom), "Unregistering twice is harmless", t)
+}
@@ -0,0 +1,82 @@
+package magicsql
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"sync"
+)
+
+// Converter translates between a Go type the database driver doesn't
+// understand, such as a third-party type which can't be given Scan and Value
+// methods, and values the driver does understand.  A registered Converter
+// takes precedence over sql.Scanner and driver.Valuer implementations.
+type Converter struct {
+	// ToDB converts a field's value, which is always of the registered type,
+	// into a value for the driver.  If nil, fields are passed to the driver
+	// as-is.
+	ToDB func(value interface{}) (driver.Value, error)
+
+	// FromDB stores a column value into dest, which is always a pointer to a
+	// value of the registered type.  src is nil when the column is NULL.  If
+	// nil, fields are scanned as if there were no Converter.
+	FromDB func(src interface{}, dest interface{}) error
+}
+
+// converters holds the package-wide converters, used by every DB
+var converters struct {
+	sync.RWMutex
+	byType map[reflect.Type]Converter
+}
+
+// RegisterConverter sets the Converter for the type of example (e.g.,
+// time.Duration(0) or net.IP(nil)) for every DB.  Fields which are pointers to
+// that type are converted as well, with nil pointers stored as NULL.
+func RegisterConverter(example interface{}, c Converter) {
+	converters.Lock()
+	if converters.byType == nil {
+		converters.byType = make(map[reflect.Type]Converter)
+	}
+	converters.byType[reflect.TypeOf(example)] = c
+	converters.Unlock()
+}
+
+// RegisterConverter sets the Converter for the type of example, for this DB
+// only.  Its converters take precedence over the package-wide ones.  It's
+// safe to call while Operations are running, though calls they're already
+// making may not see the new Converter.
+func (db *DB) RegisterConverter(example interface{}, c Converter) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var byType = make(map[reflect.Type]Converter, len(db.converters)+1)
+	for t, conv := range db.converters {
+		byType[t] = conv
+	}
+	byType[reflect.TypeOf(example)] = c
+	db.converters = byType
+}
+
+// lookupConverter returns the Converter for t from local, if there is one,
+// or else from the package-wide converters
+func lookupConverter(local map[reflect.Type]Converter, t reflect.Type) (Converter, bool) {
+	if c, ok := local[t]; ok {
+		return c, true
+	}
+
+	converters.RLock()
+	var c, ok = converters.byType[t]
+	converters.RUnlock()
+	return c, ok
+}
+
+// convertedArg is the Exec argument for a field with a Converter.  The
+// conversion happens when the driver asks for its value, so errors are
+// reported by the Exec call like any other argument problem.
+type convertedArg struct {
+	toDB  func(interface{}) (driver.Value, error)
+	value interface{}
+}
+
+// Value implements driver.Valuer
+func (a convertedArg) Value() (driver.Value, error) {
+	return a.toDB(a.value)
+}
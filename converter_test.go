@@ -0,0 +1,164 @@
+package magicsql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+type Mood string
+
+type ConvFoo struct {
+	ID      int `sql:",primary"`
+	Addr    net.IP
+	Home    *url.URL
+	Timeout time.Duration
+	Mood    Mood
+}
+
+func getdbConv() *DB {
+	var db, err = Open("sqlite3", "./test.db")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.DataSource().Exec(`
+		drop table if exists conv_foos;
+		create table conv_foos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			addr text,
+			home text,
+			timeout text,
+			mood text
+		);
+	`)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+func init() {
+	RegisterConverter(net.IP(nil), Converter{
+		ToDB: func(v interface{}) (driver.Value, error) {
+			if v.(net.IP) == nil {
+				return nil, nil
+			}
+			return v.(net.IP).String(), nil
+		},
+		FromDB: func(src interface{}, dest interface{}) error {
+			if src == nil {
+				*dest.(*net.IP) = nil
+				return nil
+			}
+			var ip = net.ParseIP(fmt.Sprintf("%s", src))
+			if ip == nil {
+				return fmt.Errorf("invalid IP %q", src)
+			}
+			*dest.(*net.IP) = ip
+			return nil
+		},
+	})
+}
+
+func TestConverters(t *testing.T) {
+	var db = getdbConv()
+	db.RegisterConverter(url.URL{}, Converter{
+		ToDB: func(v interface{}) (driver.Value, error) {
+			var u = v.(url.URL)
+			return u.String(), nil
+		},
+		FromDB: func(src interface{}, dest interface{}) error {
+			var u, err = url.Parse(fmt.Sprintf("%s", src))
+			if err == nil {
+				*dest.(*url.URL) = *u
+			}
+			return err
+		},
+	})
+	db.RegisterConverter(time.Duration(0), Converter{
+		ToDB: func(v interface{}) (driver.Value, error) { return v.(time.Duration).String(), nil },
+		FromDB: func(src interface{}, dest interface{}) error {
+			var d, err = time.ParseDuration(fmt.Sprintf("%s", src))
+			*dest.(*time.Duration) = d
+			return err
+		},
+	})
+
+	var op = db.Operation()
+	var home, _ = url.Parse("https://example.com/home")
+	op.Save("conv_foos", &ConvFoo{Addr: net.ParseIP("10.0.0.1"), Home: home, Timeout: 90 * time.Second, Mood: "happy"})
+	op.Save("conv_foos", &ConvFoo{})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var raw string
+	db.DataSource().QueryRow("SELECT addr || ' ' || home || ' ' || timeout || ' ' || mood FROM conv_foos WHERE id = 1").Scan(&raw)
+	assert.Equal("10.0.0.1 https://example.com/home 1m30s happy", raw, "Converters were used for storage", t)
+
+	var foos []*ConvFoo
+	op.Select("conv_foos", &ConvFoo{}).Order("id").AllObjects(&foos)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("10.0.0.1", foos[0].Addr.String(), "Package-wide converter read the IP", t)
+	assert.Equal("example.com", foos[0].Home.Host, "DB converter read through a pointer", t)
+	assert.Equal(90*time.Second, foos[0].Timeout, "DB converter read the duration", t)
+	assert.Equal(Mood("happy"), foos[0].Mood, "Named string type is read", t)
+	assert.True(foos[1].Addr == nil, "NULL is passed to the converter", t)
+	assert.True(foos[1].Home == nil, "NULL into a pointer is nil without calling the converter", t)
+
+	// Without the DB's converters, durations are plain integers
+	var other = getdbConv()
+	other.Operation().Save("conv_foos", &ConvFoo{Timeout: time.Second})
+	other.DataSource().QueryRow("SELECT timeout FROM conv_foos WHERE id = 1").Scan(&raw)
+	assert.Equal("1000000000", raw, "Other DBs don't use the converter", t)
+}
+
+func TestConverterErrors(t *testing.T) {
+	var db = getdbConv()
+	db.DataSource().Exec("INSERT INTO conv_foos (addr) VALUES ('not an ip')")
+
+	var op = db.Operation()
+	var foo = &ConvFoo{}
+	op.Select("conv_foos", foo).First(foo)
+	var oe *OpError
+	assert.True(errors.As(op.Err(), &oe) && oe.Phase == PhaseScan,
+		fmt.Sprintf("FromDB error (%s) is stored as a scan error", op.Err()), t)
+
+	var failure = errors.New("no thanks")
+	db.RegisterConverter(Mood(""), Converter{
+		ToDB: func(v interface{}) (driver.Value, error) { return nil, failure },
+	})
+	op = db.Operation()
+	op.Save("conv_foos", &ConvFoo{Mood: "grumpy"})
+	assert.True(errors.Is(op.Err(), failure), fmt.Sprintf("ToDB error (%s) is stored", op.Err()), t)
+}
+
+func TestConverterConcurrency(t *testing.T) {
+	var db = getdbConv()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			db.RegisterConverter(Mood(""), Converter{})
+			db.SetStrict(i%2 == 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			var op = db.Operation()
+			op.Save("conv_foos", &ConvFoo{Mood: "busy"})
+			var foo = &ConvFoo{}
+			op.Select("conv_foos", foo).First(foo)
+		}
+	}()
+	wg.Wait()
+}
@@ -14,7 +14,7 @@ type Count struct {
 }
 
 func countSQL(s Select) string {
-	var sql = fmt.Sprintf("SELECT COUNT(*) FROM %s", s.ot.t.Name)
+	var sql = fmt.Sprintf("SELECT COUNT(*) FROM %s", s.ot.t.sqlFor(s.ot.dialect()).name)
 	if s.where != "" {
 		sql += fmt.Sprintf(" WHERE %s", s.where)
 	}
@@ -4,23 +4,43 @@
 package magicsql
 
 import (
+	"context"
 	"database/sql"
+	"reflect"
+	"sync"
 )
 
-type errorable interface {
-	Err() error
-	SetErr(error)
-}
-
 // ConfigTags is a string-to-string map for treating untagged structures as if
 // they were tagged at runtime
 type ConfigTags map[string]string
 
 // DB wraps an sql.DB, providing the Operation spawner for deferred-error
 // database operations.  Like sql.DB, this DB type is meant to live more or
-// less globally and be a long-living object.
+// less globally and be a long-living object.  The wrapped sql.DB is the
+// primary database; read replicas can be added with AddReplicas.
 type DB struct {
-	db *sql.DB
+	// mu guards settings which may change while Operations are running
+	mu sync.RWMutex
+
+	db      *sql.DB
+	dialect Dialect
+	txOpts  *sql.TxOptions
+	retry   *RetryPolicy
+
+	replicas        []*sql.DB
+	replicaStrategy ReplicaStrategy
+	nextReplica     uint32
+
+	stmts  *stmtCache
+	hooks  []Hook
+	logger *QueryLogger
+
+	redactArgs bool
+	strict     bool
+	converters map[reflect.Type]Converter
+	timeConfig *TimeConfig
+	naming     NamingStrategy
+	tagKeys    []string
 }
 
 // Open attempts to connect to a database, wrapping the sql.Open call,
@@ -46,6 +66,56 @@ func (db *DB) DataSource() *sql.DB {
 	return db.db
 }
 
+// SetDialect tells the DB which SQL dialect to use for all SQL generated by
+// its Operations.  Without a dialect, Generic is used.
+func (db *DB) SetDialect(d Dialect) {
+	db.mu.Lock()
+	db.dialect = d
+	db.mu.Unlock()
+}
+
+// Dialect returns the DB's SQL dialect
+func (db *DB) Dialect() Dialect {
+	if d := db.ownDialect(); d != nil {
+		return d
+	}
+	return Generic
+}
+
+// ownDialect returns the dialect given to SetDialect, or nil if there isn't one
+func (db *DB) ownDialect() Dialect {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.dialect
+}
+
+// SetTxOptions sets the default transaction options, such as isolation level
+// and read-only mode, which are inherited by every Operation created after
+// this call.  nil means the driver's defaults.
+func (db *DB) SetTxOptions(opts *sql.TxOptions) {
+	db.mu.Lock()
+	db.txOpts = opts
+	db.mu.Unlock()
+}
+
+// SetStrict turns strict conversion on or off for every structure scanned
+// through this DB's Operations.  In strict mode, a column value which can't be
+// stored in its field without losing data, such as an overflowing integer, an
+// unparseable string, or a NULL in a non-pointer field, is stored on the
+// Operation as an error wrapping a *ConversionError.
+func (db *DB) SetStrict(strict bool) {
+	db.mu.Lock()
+	db.strict = strict
+	db.mu.Unlock()
+}
+
+// fieldConfig returns the DB's settings for converting structure fields
+func (db *DB) fieldConfig() fieldConfig {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fieldConfig{strict: db.strict, converters: db.converters, times: db.timeConfig}
+}
+
 // Operation returns an Operation instance, suitable for a short-lived task.
 // This is the entry point for any of the sql wrapped magic.  An Operation
 // should be considered a short-lived object which is not safe for concurrent
@@ -56,3 +126,12 @@ func (db *DB) DataSource() *sql.DB {
 func (db *DB) Operation() *Operation {
 	return NewOperation(db)
 }
+
+// OperationContext works like Operation, but every database call the
+// Operation makes (including those from its statements, transactions, and
+// selects) will use ctx.  If ctx is cancelled or its deadline passes, the
+// context's error becomes the Operation's error, and errors.Is matches
+// ErrTimeout for an expired deadline.
+func (db *DB) OperationContext(ctx context.Context) *Operation {
+	return NewOperationContext(ctx, db)
+}
@@ -7,8 +7,11 @@
 package magicsql
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/Nerdmaster/magicsql/assert"
 
@@ -150,3 +153,36 @@ func TestSaveUsingTable(t *testing.T) {
 	assert.Equal(1, newFoo.TwO, "newFoo.TwO was auto-populated", t)
 	assert.Equal(1, fooList[0].TwO, "fooList[0].TwO was auto-populated", t)
 }
+
+func TestOperationContextCancelled(t *testing.T) {
+	var db = getdb()
+	var ctx, cancel = context.WithCancel(context.Background())
+	var op = db.OperationContext(ctx)
+
+	op.Save("foos", &Foo{ONE: "before cancel"})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	cancel()
+	var fooList []*Foo
+	op.Select("foos", &Foo{}).AllObjects(&fooList)
+	assert.True(errors.Is(op.Err(), context.Canceled), "Cancelled context is the Operation's error", t)
+	assert.Equal(0, len(fooList), "No objects were read after cancelling", t)
+}
+
+func TestOperationContextDeadline(t *testing.T) {
+	var db = getdb()
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var op = db.OperationContext(ctx)
+
+	op.Save("foos", &Foo{ONE: "before deadline"})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	<-ctx.Done()
+	op.Save("foos", &Foo{ONE: "after deadline"})
+	assert.True(errors.Is(op.Err(), context.DeadlineExceeded), "Expired deadline is the Operation's error", t)
+	assert.True(errors.Is(op.Err(), ErrTimeout), "Expired deadline is classified as a timeout", t)
+
+	var opErr *OpError
+	assert.True(errors.As(op.Err(), &opErr) && opErr.Phase == PhaseExec, "Error records the call that was skipped", t)
+}
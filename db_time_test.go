@@ -1,7 +1,9 @@
 package magicsql
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -65,3 +67,120 @@ func TestSaveTime(t *testing.T) {
 	assert.Equal(time.Unix(1474000000, 0).UTC().String(), ft2[0].UpdatedAt.UTC().String(),
 		"After saving and reloading multiple times, updated_at is still correct", t)
 }
+
+func TestTimeParsing(t *testing.T) {
+	var db = getdbTime()
+	db.DataSource().Exec(`INSERT INTO foo_times (updated_at) VALUES ('2016-09-04 14:40:00.123456')`)
+	db.DataSource().Exec(`INSERT INTO foo_times (updated_at) VALUES ('2016-09-04T14:40:00+02:00')`)
+	db.DataSource().Exec(`INSERT INTO foo_times (updated_at) VALUES ('2016-09-04')`)
+
+	var op = db.Operation()
+	var list []*FooTime
+	op.Select("foo_times", &FooTime{}).Order("id").AllObjects(&list)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(123456000, list[0].UpdatedAt.Nanosecond(), "Fractional seconds are parsed", t)
+	assert.Equal(time.Date(2016, 9, 4, 12, 40, 0, 0, time.UTC).String(), list[1].UpdatedAt.UTC().String(),
+		"RFC3339 is parsed", t)
+	assert.Equal(time.Date(2016, 9, 4, 0, 0, 0, 0, time.UTC).String(), list[2].UpdatedAt.UTC().String(),
+		"Date-only text is parsed", t)
+
+	db.DataSource().Exec(`INSERT INTO foo_times (updated_at) VALUES ('04/09/2016 14:40')`)
+	op.Select("foo_times", &FooTime{}).Where("id = ?", 4).First(&FooTime{})
+	var ce *ConversionError
+	var pe *time.ParseError
+	var isConversion = errors.As(op.Err(), &ce) && errors.Is(ce, ErrInvalidValue) && errors.As(ce, &pe)
+	assert.True(isConversion, fmt.Sprintf("Unparseable time (%s) is an error even when not strict", op.Err()), t)
+	if isConversion {
+		assert.Equal("FooTime.UpdatedAt", ce.Field, "Error names the field", t)
+	}
+
+	db.DataSource().Exec(`INSERT INTO foo_times (updated_at) VALUES (1473000000)`)
+	op = db.Operation()
+	op.Select("foo_times", &FooTime{}).Where("id = ?", 5).First(&FooTime{})
+	assert.True(errors.As(op.Err(), &ce) && errors.Is(ce, ErrInvalidValue),
+		fmt.Sprintf("Integer in an untagged time field (%s) is an error even when not strict", op.Err()), t)
+
+	var berlin, _ = time.LoadLocation("Europe/Berlin")
+	if berlin == nil {
+		berlin = time.FixedZone("CEST", 2*60*60)
+	}
+	db.SetTimeConfig(TimeConfig{Layouts: []string{"02/01/2006 15:04"}, Location: berlin})
+	op = db.Operation()
+	var ft = &FooTime{}
+	op.Select("foo_times", ft).Where("id = ?", 4).First(ft)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(time.Date(2016, 9, 4, 14, 40, 0, 0, berlin).String(), ft.UpdatedAt.String(),
+		"Custom layout is parsed in the storage zone", t)
+}
+
+func TestTimeLocation(t *testing.T) {
+	var db = getdbTime()
+	db.SetTimeConfig(TimeConfig{Location: time.UTC})
+
+	var op = db.Operation()
+	var est = time.FixedZone("EST", -5*60*60)
+	op.Save("foo_times", &FooTime{CreatedAt: time.Date(2016, 9, 4, 10, 0, 0, 0, est)})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var raw string
+	db.DataSource().QueryRow("SELECT created_at FROM foo_times").Scan(&raw)
+	assert.True(strings.HasPrefix(raw, "2016-09-04 15:00:00") || strings.HasPrefix(raw, "2016-09-04T15:00:00"),
+		fmt.Sprintf("Time (%s) is stored in UTC", raw), t)
+
+	var ft = &FooTime{}
+	op.Select("foo_times", ft).First(ft)
+	assert.Equal(time.UTC, ft.CreatedAt.Location(), "Time is read in UTC", t)
+}
+
+type EpochFoo struct {
+	ID      int        `sql:",primary"`
+	Seconds time.Time  `sql:",unix"`
+	Millis  *time.Time `sql:",unixms"`
+	Born    time.Time  `sql:",date"`
+	Seen    time.Time  `sql:",noupdate,layout=Mon, 02 Jan 2006 15:04:05 MST"`
+}
+
+func TestTimeTagOptions(t *testing.T) {
+	var db = getdbTime()
+	db.DataSource().Exec(`
+		drop table if exists epoch_foos;
+		create table epoch_foos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			seconds int,
+			millis int,
+			born text,
+			seen text
+		);
+	`)
+
+	var op = db.Operation()
+	var when = time.Unix(1473000000, 123000000)
+	var seen = time.Date(2016, 9, 4, 14, 40, 0, 0, time.UTC)
+	op.Save("epoch_foos", &EpochFoo{Seconds: when, Millis: &when, Born: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), Seen: seen})
+	op.Save("epoch_foos", &EpochFoo{})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var seconds, millis int64
+	var born, seenText string
+	db.DataSource().QueryRow("SELECT seconds, millis, born, seen FROM epoch_foos WHERE id = 1").Scan(&seconds, &millis, &born, &seenText)
+	assert.Equal(int64(1473000000), seconds, "unix stores seconds", t)
+	assert.Equal(int64(1473000000123), millis, "unixms stores milliseconds", t)
+	assert.Equal("1990-05-17", born, "date stores the date only", t)
+	assert.Equal("Sun, 04 Sep 2016 14:40:00 UTC", seenText, "Layouts may contain commas", t)
+	assert.True(Table("epoch_foos", &EpochFoo{}).sqlFields[4].NoUpdate, "Options before a layout still apply", t)
+
+	var list []*EpochFoo
+	op.Select("epoch_foos", &EpochFoo{}).Order("id").AllObjects(&list)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(int64(1473000000), list[0].Seconds.Unix(), "unix is read", t)
+	assert.Equal(when.UnixMilli(), list[0].Millis.UnixMilli(), "unixms is read through a pointer", t)
+	assert.Equal("1990-05-17", list[0].Born.Format("2006-01-02"), "date is read", t)
+	assert.Equal(seen.String(), list[0].Seen.UTC().String(), "Layout with commas is read", t)
+	assert.True(list[1].Millis == nil, "Nil pointer is NULL", t)
+
+	op.Save("epoch_foos", list[0])
+	var again = &EpochFoo{}
+	op.Table("epoch_foos", again).Find(again, 1)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("1990-05-17", again.Born.Format("2006-01-02"), "date doesn't move when saved again", t)
+}
@@ -0,0 +1,225 @@
+package magicsql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect describes the differences between database engines which matter to
+// the SQL generated by this package: how bind parameters are written, how
+// table and column names are quoted, how a SELECT is limited, and how boolean
+// literals look.  A DB uses the Generic dialect unless told otherwise.
+type Dialect interface {
+	// Placeholder returns the bind parameter for the nth argument, where n
+	// starts at 1
+	Placeholder(n int) string
+
+	// Quote returns a single identifier (no dots) quoted for safe use as a
+	// table or column name
+	Quote(ident string) string
+
+	// LimitOffset returns the clause, including its leading space, which
+	// limits a SELECT to the given number of rows after skipping offset rows.
+	// Zero means "not set" for both values.  ordered is true when the query
+	// already has an ORDER BY clause.
+	LimitOffset(limit, offset uint64, ordered bool) string
+
+	// Bool returns the SQL literal for a boolean value
+	Bool(b bool) string
+}
+
+// Built-in dialects.  Generic reproduces this package's historical SQL: "?"
+// placeholders and bare identifiers.
+var (
+	Generic    Dialect = genericDialect{}
+	SQLite     Dialect = sqliteDialect{}
+	MySQL      Dialect = mysqlDialect{}
+	PostgreSQL Dialect = postgresDialect{}
+	SQLServer  Dialect = sqlServerDialect{}
+)
+
+// SavepointDialect may be implemented by a Dialect whose savepoint syntax
+// differs from the SQL standard's SAVEPOINT, ROLLBACK TO SAVEPOINT, and
+// RELEASE SAVEPOINT.  ReleaseSavepoint may return an empty string if the
+// database has no way to release a savepoint.
+type SavepointDialect interface {
+	Savepoint(name string) string
+	RollbackToSavepoint(name string) string
+	ReleaseSavepoint(name string) string
+}
+
+func savepointSQL(d Dialect, name string) string {
+	if sd, ok := d.(SavepointDialect); ok {
+		return sd.Savepoint(name)
+	}
+	return "SAVEPOINT " + d.Quote(name)
+}
+
+func rollbackToSavepointSQL(d Dialect, name string) string {
+	if sd, ok := d.(SavepointDialect); ok {
+		return sd.RollbackToSavepoint(name)
+	}
+	return "ROLLBACK TO SAVEPOINT " + d.Quote(name)
+}
+
+func releaseSavepointSQL(d Dialect, name string) string {
+	if sd, ok := d.(SavepointDialect); ok {
+		return sd.ReleaseSavepoint(name)
+	}
+	return "RELEASE SAVEPOINT " + d.Quote(name)
+}
+
+// ReturningDialect may be implemented by a Dialect whose drivers can't report
+// an inserted row's ID via LastInsertId, but which can return columns from the
+// inserted row instead.  InsertReturning is given the quoted table name, the
+// quoted column list, the placeholder list, and the quoted primary key column,
+// and returns an INSERT which yields the new key as a single-row result.
+type ReturningDialect interface {
+	InsertReturning(table, columns, values, key string) string
+}
+
+// quoteName quotes a possibly schema-qualified name (e.g., "public.users") by
+// quoting each dot-separated part on its own
+func quoteName(d Dialect, name string) string {
+	var parts = strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = d.Quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+type genericDialect struct{}
+
+func (genericDialect) Placeholder(n int) string  { return "?" }
+func (genericDialect) Quote(ident string) string { return ident }
+func (genericDialect) Bool(b bool) string        { return strings.ToUpper(fmt.Sprint(b)) }
+
+func (genericDialect) LimitOffset(limit, offset uint64, ordered bool) string {
+	var sql string
+	if limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return sql
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(n int) string { return "?" }
+func (sqliteDialect) Quote(ident string) string {
+	return `"` + strings.Replace(ident, `"`, `""`, -1) + `"`
+}
+
+func (sqliteDialect) Bool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// LimitOffset for SQLite uses "LIMIT -1" when only an offset is given, since
+// SQLite doesn't allow OFFSET without LIMIT
+func (sqliteDialect) LimitOffset(limit, offset uint64, ordered bool) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+	var sql = " LIMIT -1"
+	if limit > 0 {
+		sql = fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return sql
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(n int) string { return "?" }
+func (mysqlDialect) Quote(ident string) string {
+	return "`" + strings.Replace(ident, "`", "``", -1) + "`"
+}
+func (mysqlDialect) Bool(b bool) string { return strings.ToUpper(fmt.Sprint(b)) }
+
+// LimitOffset for MySQL uses the largest possible limit when only an offset is
+// given, as recommended by the MySQL docs
+func (mysqlDialect) LimitOffset(limit, offset uint64, ordered bool) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+	var sql = " LIMIT 18446744073709551615"
+	if limit > 0 {
+		sql = fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return sql
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+func (postgresDialect) Quote(ident string) string {
+	return `"` + strings.Replace(ident, `"`, `""`, -1) + `"`
+}
+func (postgresDialect) Bool(b bool) string { return strings.ToUpper(fmt.Sprint(b)) }
+
+func (postgresDialect) LimitOffset(limit, offset uint64, ordered bool) string {
+	return genericDialect{}.LimitOffset(limit, offset, ordered)
+}
+
+func (postgresDialect) InsertReturning(table, columns, values, key string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s", table, columns, values, key)
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Placeholder(n int) string { return fmt.Sprintf("@p%d", n) }
+func (sqlServerDialect) Quote(ident string) string {
+	return "[" + strings.Replace(ident, "]", "]]", -1) + "]"
+}
+
+func (sqlServerDialect) Bool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// LimitOffset for SQL Server uses OFFSET / FETCH, which requires an ORDER BY
+// clause, so a no-op ordering is added when the query has none
+func (sqlServerDialect) LimitOffset(limit, offset uint64, ordered bool) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+	var sql string
+	if !ordered {
+		sql = " ORDER BY (SELECT NULL)"
+	}
+	sql += fmt.Sprintf(" OFFSET %d ROWS", offset)
+	if limit > 0 {
+		sql += fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", limit)
+	}
+	return sql
+}
+
+func (sqlServerDialect) InsertReturning(table, columns, values, key string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) OUTPUT INSERTED.%s VALUES (%s)", table, columns, key, values)
+}
+
+func (sqlServerDialect) Savepoint(name string) string {
+	return "SAVE TRANSACTION " + sqlServerDialect{}.Quote(name)
+}
+
+func (sqlServerDialect) RollbackToSavepoint(name string) string {
+	return "ROLLBACK TRANSACTION " + sqlServerDialect{}.Quote(name)
+}
+
+// ReleaseSavepoint returns an empty string, as SQL Server has no way to
+// release a savepoint; they simply go away when the transaction ends
+func (sqlServerDialect) ReleaseSavepoint(name string) string {
+	return ""
+}
@@ -0,0 +1,86 @@
+package magicsql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func dialectOp(d Dialect) *Operation {
+	var db = &DB{}
+	db.SetDialect(d)
+	return &Operation{parent: db}
+}
+
+func TestPostgreSQLDialect(t *testing.T) {
+	var table = Table("foos", &Foo{})
+	table.Dialect = PostgreSQL
+	assert.Equal(`INSERT INTO "foos" ("one","tree","four","four_point_five") VALUES ($1,$2,$3,$4)`,
+		table.InsertSQL(), "Insert SQL", t)
+	assert.Equal(`UPDATE "foos" SET "one" = $1,"tree" = $2,"four" = $3,"four_point_five" = $4 WHERE "two" = $5`,
+		table.UpdateSQL(), "Update SQL", t)
+	assert.Equal(`INSERT INTO "foos" ("one","tree","four","four_point_five") VALUES ($1,$2,$3,$4) RETURNING "two"`,
+		table.insertReturningSQL(PostgreSQL), "Insert SQL returning the primary key", t)
+
+	var s = dialectOp(PostgreSQL).Select("public.foos", &Foo{}).Where("one = $1", "x").Offset(5)
+	assert.Equal(`SELECT "one","two","tree","four","four_point_five","seven" FROM "public"."foos" WHERE one = $1 OFFSET 5`,
+		s.SQL(), "Select SQL", t)
+}
+
+func TestSQLServerDialect(t *testing.T) {
+	var op = dialectOp(SQLServer)
+	var s = op.Select("foos", &Foo{}).Limit(10)
+	assert.Equal("SELECT [one],[two],[tree],[four],[four_point_five],[seven] FROM [foos]"+
+		" ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY", s.SQL(), "Select SQL without order", t)
+
+	s = s.Order("one").Offset(20)
+	assert.Equal("SELECT [one],[two],[tree],[four],[four_point_five],[seven] FROM [foos]"+
+		" ORDER BY one OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY", s.SQL(), "Select SQL with order", t)
+
+	assert.Equal("UPDATE [foos] SET [one] = @p1,[tree] = @p2,[four] = @p3,[four_point_five] = @p4 WHERE [two] = @p5",
+		op.Table("foos", &Foo{}).t.updateSQL(SQLServer), "Update SQL", t)
+	assert.Equal("INSERT INTO [foos] ([one],[tree],[four],[four_point_five]) OUTPUT INSERTED.[two] VALUES (@p1,@p2,@p3,@p4)",
+		op.Table("foos", &Foo{}).t.insertReturningSQL(SQLServer), "Insert SQL returning the primary key", t)
+}
+
+// returningSQLite reads new keys back with SQLite's RETURNING clause, which
+// works the same way as PostgreSQL's
+type returningSQLite struct {
+	sqliteDialect
+}
+
+func (returningSQLite) InsertReturning(table, columns, values, key string) string {
+	return postgresDialect{}.InsertReturning(table, columns, values, key)
+}
+
+func TestSaveReturning(t *testing.T) {
+	var db = getdb()
+	db.SetDialect(returningSQLite{})
+	db.DataSource().Exec("INSERT INTO foos (one,two) VALUES (?, ?)", "existing", 41)
+
+	var op = db.Operation()
+	var foo = &Foo{ONE: "new"}
+	var res = op.Save("foos", foo)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(42, foo.TwO, "Primary key is read back from the insert", t)
+	assert.Equal(int64(42), res.LastInsertId(), "Result reports the returned key", t)
+	assert.Equal(int64(1), res.RowsAffected(), "Result reports the inserted row", t)
+
+	var got = &Foo{}
+	op.Table("foos", got).Find(got, 42)
+	assert.Equal("new", got.ONE, "Record was inserted", t)
+}
+
+func TestSQLiteAndMySQLDialects(t *testing.T) {
+	var s = dialectOp(SQLite).Select("foos", &Foo{}).Offset(5)
+	assert.Equal(`SELECT "one","two","tree","four","four_point_five","seven" FROM "foos" LIMIT -1 OFFSET 5`,
+		s.SQL(), "SQLite offset without limit", t)
+	assert.Equal(`SELECT COUNT(*) FROM "foos"`, s.Count().SQL(), "SQLite count", t)
+
+	s = dialectOp(MySQL).Select("order", &Foo{}).Limit(3)
+	assert.Equal("SELECT `one`,`two`,`tree`,`four`,`four_point_five`,`seven` FROM `order` LIMIT 3",
+		s.SQL(), "MySQL quoting of reserved words", t)
+	assert.Equal("TRUE", MySQL.Bool(true), "MySQL boolean literal", t)
+	assert.Equal("0", SQLite.Bool(false), "SQLite boolean literal", t)
+}
@@ -0,0 +1,224 @@
+package magicsql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Phase identifies the step of a database call which failed
+type Phase string
+
+// Phases recorded in an OpError
+const (
+	PhaseBegin   Phase = "begin"
+	PhasePrepare Phase = "prepare"
+	PhaseQuery   Phase = "query"
+	PhaseExec    Phase = "exec"
+	PhaseScan    Phase = "scan"
+	PhaseCommit  Phase = "commit"
+)
+
+// RedactedArg replaces each argument in an OpError when the DB has been told
+// to redact arguments
+const RedactedArg = "[redacted]"
+
+// OpError wraps an error returned by the database driver (or by scanning its
+// results), recording what the Operation was doing at the time.  Use
+// errors.Is or errors.As to get at the underlying driver error.
+//
+// If the driver error could be classified, errors.Is also matches the
+// matching sentinel (ErrUniqueViolation, ErrDeadlock, etc.), and the
+// constraint, table, and column are filled in if the driver supplied them.
+type OpError struct {
+	Phase Phase
+
+	// SQL and Args are the statement and arguments involved.  Args hold the
+	// values as they were when the error occurred, or RedactedArg for each
+	// argument if the DB redacts them.  Both are empty for begin and commit.
+	SQL  string
+	Args []interface{}
+
+	// File and Line locate the first caller outside this package
+	File string
+	Line int
+
+	Err error
+
+	// Kind is the sentinel error describing Err, or nil if Err couldn't be
+	// classified
+	Kind       error
+	Constraint string
+	Table      string
+	Column     string
+}
+
+// Error implements the error interface
+func (e *OpError) Error() string {
+	if e.SQL == "" {
+		return fmt.Sprintf("magicsql: %s: %s", e.Phase, e.Err)
+	}
+	return fmt.Sprintf("magicsql: %s %q: %s", e.Phase, e.SQL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error e was classified as
+func (e *OpError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// ErrNotFound is matched by errors.Is when a lookup which required a row found
+// none.  The error actually stored is a *NotFoundError.
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError is stored on an Operation when a Select required a row but
+// none matched.  It matches ErrNotFound and sql.ErrNoRows with errors.Is.
+// Args are snapshotted and redacted the same way as an OpError's.
+type NotFoundError struct {
+	Table string
+	Where string
+	Args  []interface{}
+}
+
+// Error implements the error interface
+func (e *NotFoundError) Error() string {
+	if e.Where == "" {
+		return fmt.Sprintf("magicsql: no rows found in %s", e.Table)
+	}
+	return fmt.Sprintf("magicsql: no rows found in %s where %s", e.Table, e.Where)
+}
+
+// Unwrap returns sql.ErrNoRows
+func (e *NotFoundError) Unwrap() error {
+	return sql.ErrNoRows
+}
+
+// Is reports whether target is ErrNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// Reasons a strict NullableField refuses a value, matched by errors.Is on the
+// *ConversionError it returns
+var (
+	ErrOverflow       = errors.New("value overflows field")
+	ErrSignLoss       = errors.New("negative value for unsigned field")
+	ErrInvalidValue   = errors.New("value can't be converted to field type")
+	ErrUnexpectedNull = errors.New("NULL for non-nullable field")
+)
+
+// ConversionError describes a column value which strict conversion refused to
+// store in a field.  When scanning, it ends up wrapped in the OpError stored
+// on the Operation.
+type ConversionError struct {
+	Column string
+	Field  string
+	Value  interface{}
+	Err    error
+}
+
+// Error implements the error interface
+func (e *ConversionError) Error() string {
+	switch v := e.Value.(type) {
+	case nil:
+		return fmt.Sprintf("magicsql: column %q into field %s: %s", e.Column, e.Field, e.Err)
+	case []byte:
+		return fmt.Sprintf("magicsql: column %q into field %s: %s (%q)", e.Column, e.Field, e.Err, v)
+	}
+	return fmt.Sprintf("magicsql: column %q into field %s: %s (%#v)", e.Column, e.Field, e.Err, e.Value)
+}
+
+// Unwrap returns the reason the value was refused
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// SetRedactArgs controls whether the arguments stored in an OpError are
+// replaced with RedactedArg, for when errors may end up somewhere sensitive
+// data shouldn't.  Operations created afterward inherit the setting.
+func (db *DB) SetRedactArgs(redact bool) {
+	db.mu.Lock()
+	db.redactArgs = redact
+	db.mu.Unlock()
+}
+
+// wrapErr builds an OpError around err, or returns nil if err is nil.  Errors
+// which are already OpErrors are returned as-is.
+func (op *Operation) wrapErr(phase Phase, query string, args []interface{}, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*OpError); ok {
+		return err
+	}
+
+	var e = &OpError{Phase: phase, SQL: query, Err: err}
+	e.File, e.Line = externalCaller()
+	if c := Classify(err); c != nil {
+		e.Kind, e.Constraint, e.Table, e.Column = c.Kind, c.Constraint, c.Table, c.Column
+	}
+	e.Args = op.errArgs(args)
+	return e
+}
+
+// errArgs copies args for storing in an error: each is snapshotted, or
+// replaced with RedactedArg if the Operation redacts arguments
+func (op *Operation) errArgs(args []interface{}) []interface{} {
+	var out []interface{}
+	for _, arg := range args {
+		if op.redactArgs {
+			out = append(out, RedactedArg)
+		} else {
+			out = append(out, snapshotArg(arg))
+		}
+	}
+	return out
+}
+
+// setErr wraps err with wrapErr and stores it
+func (op *Operation) setErr(phase Phase, query string, args []interface{}, err error) {
+	op.SetErr(op.wrapErr(phase, query, args, err))
+}
+
+// snapshotArg dereferences pointer arguments so the error keeps the value that
+// was sent, not a pointer into a structure which may change later
+func snapshotArg(arg interface{}) interface{} {
+	var rv = reflect.ValueOf(arg)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// pkgPrefix is used to skip this package's frames when finding the caller
+var pkgPrefix = reflect.TypeOf(OpError{}).PkgPath() + "."
+
+// externalCaller returns the file and line of the first stack frame outside
+// this package.  The package's own tests count as "outside".
+func externalCaller() (string, int) {
+	var pcs [32]uintptr
+	var n = runtime.Callers(3, pcs[:])
+	var frames = runtime.CallersFrames(pcs[:n])
+	for {
+		var f, more = frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPrefix) || strings.HasSuffix(f.File, "_test.go") {
+			return f.File, f.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func TestOpError(t *testing.T) {
+	var db = getdb()
+	var op = db.Operation()
+
+	var foo = &Foo{ONE: "dupe", TwO: 1}
+	op.Exec("INSERT INTO foos (one, two) VALUES (?, ?)", &foo.ONE, &foo.TwO)
+	op.Exec("INSERT INTO foos (one, two) VALUES (?, ?)", &foo.ONE, &foo.TwO)
+	foo.ONE = "changed"
+
+	var opErr *OpError
+	assert.True(errors.As(op.Err(), &opErr), "Operation error is an OpError", t)
+	assert.Equal(PhaseExec, opErr.Phase, "Phase is exec", t)
+	assert.Equal("INSERT INTO foos (one, two) VALUES (?, ?)", opErr.SQL, "SQL is recorded", t)
+	assert.Equal("dupe", opErr.Args[0], "Args hold the values at the time of the error", t)
+	assert.Equal(1, opErr.Args[1], "Args hold dereferenced values", t)
+	assert.Equal("errors_test.go", filepath.Base(opErr.File), "Caller is outside the package's code", t)
+	assert.True(opErr.Line > 0, "Caller line is recorded", t)
+	assert.True(strings.Contains(op.Err().Error(), "UNIQUE constraint failed"), "Driver message is kept", t)
+	assert.True(errors.Unwrap(op.Err()) != nil, "Driver error is reachable", t)
+}
+
+func TestOpErrorRedactedScan(t *testing.T) {
+	var db = getdb()
+	db.SetRedactArgs(true)
+	db.DataSource().Exec("INSERT INTO foos (one, two) VALUES ('x', 1)")
+
+	var op = db.Operation()
+	var n int
+	var r = op.Query("SELECT one FROM foos WHERE two = ?", 1)
+	for r.Next() {
+		r.Scan(&n)
+	}
+	r.Close()
+
+	var opErr *OpError
+	assert.True(errors.As(op.Err(), &opErr), "Scan error is an OpError", t)
+	assert.Equal(PhaseScan, opErr.Phase, "Phase is scan", t)
+	assert.Equal(RedactedArg, opErr.Args[0], "Args are redacted", t)
+}
@@ -0,0 +1,171 @@
+package magicsql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+)
+
+// QueryKind identifies the type of database call a QueryEvent describes
+type QueryKind string
+
+// Kinds of database calls seen by hooks
+const (
+	KindQuery   QueryKind = "query"
+	KindExec    QueryKind = "exec"
+	KindPrepare QueryKind = "prepare"
+)
+
+// QueryEvent describes a single database call.  Hooks see the same event
+// before and after the call, so BeforeQuery can stash data for AfterQuery in
+// the event's fields if needed.
+type QueryEvent struct {
+	Kind QueryKind
+
+	// SQL and Args are what will be (or were) sent to the database.
+	// BeforeQuery may change them; a changed SQL string is ignored when
+	// executing an already-prepared statement.
+	SQL  string
+	Args []interface{}
+
+	// InTransaction is true if the call is made inside a transaction
+	InTransaction bool
+
+	// Start is when the call began; Duration, RowsAffected, and Err are only
+	// set for AfterQuery.  RowsAffected is -1 unless this was an exec and the
+	// driver reported the count.
+	Start        time.Time
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+// Hook is called around every Query, Exec, and Prepare an Operation makes,
+// including those made by prepared statements, selects, and saves.
+//
+// BeforeQuery runs before the call and may rewrite the event's SQL or Args.
+// Returning an error vetoes the call, and the error becomes the Operation's
+// error.  AfterQuery runs once the call completes (or fails), in reverse order
+// of registration so hooks nest like middleware.  When a call is vetoed,
+// AfterQuery still runs for the hooks whose BeforeQuery had already
+// succeeded, with the veto as the event's Err, so they can close anything
+// they opened; the vetoing hook's AfterQuery isn't run.
+//
+// Rewriting the SQL bypasses the DB's statement cache, since a statement
+// prepared for one rewritten query is of no use to the next.
+type Hook interface {
+	BeforeQuery(ctx context.Context, e *QueryEvent) error
+	AfterQuery(ctx context.Context, e *QueryEvent)
+}
+
+// HookFuncs adapts plain functions to the Hook interface.  Either function
+// may be nil.
+type HookFuncs struct {
+	Before func(ctx context.Context, e *QueryEvent) error
+	After  func(ctx context.Context, e *QueryEvent)
+}
+
+// BeforeQuery implements Hook
+func (h HookFuncs) BeforeQuery(ctx context.Context, e *QueryEvent) error {
+	if h.Before == nil {
+		return nil
+	}
+	return h.Before(ctx, e)
+}
+
+// AfterQuery implements Hook
+func (h HookFuncs) AfterQuery(ctx context.Context, e *QueryEvent) {
+	if h.After != nil {
+		h.After(ctx, e)
+	}
+}
+
+// AddHook registers a hook which every Operation created afterward inherits
+func (db *DB) AddHook(h Hook) {
+	db.mu.Lock()
+	// Force a copy so Operations holding the old list never see it change
+	db.hooks = append(db.hooks[:len(db.hooks):len(db.hooks)], h)
+	db.mu.Unlock()
+}
+
+// RemoveHook unregisters a hook added with AddHook.  Operations created
+// before the call keep the hooks they inherited.  h must be comparable, such
+// as a pointer; hooks which aren't (a HookFuncs value, for instance) can't be
+// found and are left alone, so register a *HookFuncs if it needs removing.
+func (db *DB) RemoveHook(h Hook) {
+	if !reflect.ValueOf(h).Comparable() {
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var kept = make([]Hook, 0, len(db.hooks))
+	for _, existing := range db.hooks {
+		if !reflect.ValueOf(existing).Comparable() || existing != h {
+			kept = append(kept, existing)
+		}
+	}
+	db.hooks = kept
+}
+
+// AddHook registers a hook for this Operation only, in addition to those it
+// inherited from its DB
+func (op *Operation) AddHook(h Hook) {
+	// Force a copy so we never write into the DB's hook list
+	op.hooks = append(op.hooks[:len(op.hooks):len(op.hooks)], h)
+}
+
+// beforeQuery builds the event for a call and runs the BeforeQuery hooks.
+// Any error is stored on the Operation, and the event is returned either way.
+func (op *Operation) beforeQuery(kind QueryKind, query string, args []interface{}) *QueryEvent {
+	var e = &QueryEvent{
+		Kind:          kind,
+		SQL:           query,
+		Args:          args,
+		InTransaction: op.tx != nil,
+		Start:         time.Now(),
+		RowsAffected:  -1,
+	}
+
+	for i, h := range op.hooks {
+		var err = h.BeforeQuery(op.Context(), e)
+		if err != nil {
+			op.SetErr(err)
+			e.Duration = time.Since(e.Start)
+			e.Err = err
+			op.runAfterHooks(e, i)
+			break
+		}
+	}
+	return e
+}
+
+// afterQuery completes the event, runs the AfterQuery hooks, and logs the
+// call if there's a logger
+func (op *Operation) afterQuery(e *QueryEvent, r sql.Result, err error) {
+	if len(op.hooks) == 0 && op.logger == nil {
+		return
+	}
+
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	if r != nil && err == nil {
+		if n, rerr := r.RowsAffected(); rerr == nil {
+			e.RowsAffected = n
+		}
+	}
+
+	op.runAfterHooks(e, len(op.hooks))
+
+	if op.logger != nil {
+		op.logger.log(op.Context(), e)
+	}
+}
+
+// runAfterHooks runs AfterQuery for the first n hooks, in reverse order
+func (op *Operation) runAfterHooks(e *QueryEvent, n int) {
+	for i := n - 1; i >= 0; i-- {
+		op.hooks[i].AfterQuery(op.Context(), e)
+	}
+}
@@ -0,0 +1,122 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func TestHooks(t *testing.T) {
+	var db = getdb()
+	var events []*QueryEvent
+	db.AddHook(HookFuncs{
+		Before: func(ctx context.Context, e *QueryEvent) error {
+			e.SQL = "/* request 42 */ " + e.SQL
+			return nil
+		},
+		After: func(ctx context.Context, e *QueryEvent) {
+			events = append(events, e)
+		},
+	})
+
+	var op = db.Operation()
+	op.Save("foos", &Foo{ONE: "hooked"})
+	var fooList []*Foo
+	op.Select("foos", &Foo{}).AllObjects(&fooList)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	assert.Equal(2, len(events), "Two calls were seen by the hook", t)
+	assert.Equal(KindExec, events[0].Kind, "First call was the insert", t)
+	assert.True(strings.HasPrefix(events[0].SQL, "/* request 42 */ INSERT INTO foos"), "SQL was rewritten", t)
+	assert.Equal(int64(1), events[0].RowsAffected, "Rows affected was recorded", t)
+	assert.Equal("hooked", *events[0].Args[0].(*string), "Args were recorded", t)
+	assert.Equal(KindQuery, events[1].Kind, "Second call was the select", t)
+	assert.Equal(int64(-1), events[1].RowsAffected, "Rows affected is unknown for queries", t)
+	assert.Equal(false, events[1].InTransaction, "Select wasn't in a transaction", t)
+}
+
+func TestHookVeto(t *testing.T) {
+	var db = getdb()
+	var vetoed = errors.New("no writes allowed")
+	var after int
+
+	var op = db.Operation()
+	op.AddHook(HookFuncs{
+		Before: func(ctx context.Context, e *QueryEvent) error {
+			if e.Kind == KindExec {
+				return vetoed
+			}
+			return nil
+		},
+		After: func(ctx context.Context, e *QueryEvent) { after++ },
+	})
+
+	op.Save("foos", &Foo{ONE: "vetoed"})
+	assert.Equal(vetoed, op.Err(), "Veto becomes the Operation's error", t)
+	assert.Equal(0, after, "AfterQuery doesn't run for a vetoed call", t)
+	assert.Equal(0, len(db.hooks), "Operation hooks aren't added to the DB", t)
+	assert.Equal(uint64(0), countFoos(db), "Vetoed insert didn't run", t)
+}
+
+func TestHookVetoUnwinds(t *testing.T) {
+	var db = getdb()
+	var vetoed = errors.New("no writes allowed")
+	var calls []string
+	var afterErr error
+
+	var op = db.Operation()
+	op.AddHook(HookFuncs{
+		Before: func(ctx context.Context, e *QueryEvent) error {
+			calls = append(calls, "open span")
+			return nil
+		},
+		After: func(ctx context.Context, e *QueryEvent) {
+			calls = append(calls, "close span")
+			afterErr = e.Err
+		},
+	})
+	op.AddHook(HookFuncs{
+		Before: func(ctx context.Context, e *QueryEvent) error { return vetoed },
+		After:  func(ctx context.Context, e *QueryEvent) { calls = append(calls, "vetoer after") },
+	})
+	op.AddHook(HookFuncs{
+		Before: func(ctx context.Context, e *QueryEvent) error {
+			calls = append(calls, "late before")
+			return nil
+		},
+		After: func(ctx context.Context, e *QueryEvent) { calls = append(calls, "late after") },
+	})
+
+	op.Save("foos", &Foo{ONE: "vetoed"})
+	assert.Equal("open span,close span", strings.Join(calls, ","), "Only hooks whose BeforeQuery succeeded see AfterQuery", t)
+	assert.Equal(vetoed, afterErr, "AfterQuery sees the veto", t)
+}
+
+func TestHookRewriteSkipsStatementCache(t *testing.T) {
+	var db = getdb()
+	db.SetStatementCacheSize(4)
+	var n int
+	db.AddHook(HookFuncs{
+		Before: func(ctx context.Context, e *QueryEvent) error {
+			n++
+			e.SQL = fmt.Sprintf("/* request %d */ %s", n, e.SQL)
+			return nil
+		},
+	})
+
+	var op = db.Operation()
+	for i := 0; i < 3; i++ {
+		op.Save("foos", &Foo{ONE: "hooked"})
+	}
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	var stats = db.StatementCacheStats()
+	assert.Equal(uint64(0), stats.Misses, "Rewritten SQL wasn't prepared", t)
+	assert.Equal(0, stats.Size, "Rewritten SQL wasn't cached", t)
+	assert.Equal(uint64(3), countFoos(db), "Rewritten inserts ran", t)
+}
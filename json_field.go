@@ -0,0 +1,73 @@
+package magicsql
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+)
+
+// jsonArg is the Exec argument for a field tagged "json".  The field is
+// marshalled when the arg is built, so an OpError's snapshot of the arg holds
+// the JSON that was sent even if the structure changes later.  Marshalling
+// errors are held until the driver asks for the value, so they're reported by
+// the Exec call like any other argument problem.
+type jsonArg struct {
+	data driver.Value
+	err  error
+}
+
+// newJSONArg marshals v.  Nil maps, slices, pointers and interfaces are
+// stored as NULL.
+func newJSONArg(v reflect.Value) jsonArg {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return jsonArg{}
+		}
+	}
+
+	var b, err = json.Marshal(v.Interface())
+	if err != nil {
+		return jsonArg{err: err}
+	}
+	return jsonArg{data: string(b)}
+}
+
+// Value implements driver.Valuer
+func (a jsonArg) Value() (driver.Value, error) {
+	return a.data, a.err
+}
+
+// jsonField scans a JSON text column into a field tagged "json".  NULL sets
+// the field to its zero value, so maps and slices become nil.
+type jsonField struct {
+	v      reflect.Value
+	column string
+	field  string
+}
+
+// Scan implements sql.Scanner.  Failure to unmarshal the column returns a
+// *ConversionError wrapping the JSON error, regardless of strict mode.
+func (f *jsonField) Scan(src interface{}) error {
+	var data []byte
+	switch s := src.(type) {
+	case nil:
+		f.v.Set(reflect.Zero(f.v.Type()))
+		return nil
+	case string:
+		data = []byte(s)
+	case []byte:
+		data = s
+	default:
+		return &ConversionError{Column: f.column, Field: f.field, Value: src, Err: ErrInvalidValue}
+	}
+
+	// Unmarshalling into an existing map or struct merges rather than
+	// replaces, so always start from scratch
+	var p = reflect.New(f.v.Type())
+	if err := json.Unmarshal(data, p.Interface()); err != nil {
+		return &ConversionError{Column: f.column, Field: f.field, Value: src, Err: err}
+	}
+	f.v.Set(p.Elem())
+	return nil
+}
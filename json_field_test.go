@@ -0,0 +1,123 @@
+package magicsql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+type Settings struct {
+	Theme string `json:"theme"`
+	Size  int    `json:"size"`
+}
+
+type JSONFoo struct {
+	ID       int               `sql:",primary"`
+	Settings Settings          `sql:",json"`
+	Tags     []string          `sql:",json"`
+	Extra    map[string]string `sql:"attrs,json"`
+	Parent   *Settings         `sql:",json"`
+}
+
+func getdbJSON() *DB {
+	var db, err = Open("sqlite3", "./test.db")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.DataSource().Exec(`
+		drop table if exists json_foos;
+		create table json_foos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			settings text,
+			tags text,
+			attrs text,
+			parent text
+		);
+	`)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+func TestJSONFields(t *testing.T) {
+	var table = Table("json_foos", &JSONFoo{})
+	assert.Equal("id,settings,tags,attrs,parent", strings.Join(table.FieldNames(), ","), "JSON structs aren't inlined", t)
+
+	var db = getdbJSON()
+	var op = db.Operation()
+	op.Save("json_foos", &JSONFoo{Settings: Settings{"dark", 2}, Tags: []string{"a", "b"}, Extra: map[string]string{"k": "v"}})
+	op.Save("json_foos", &JSONFoo{})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var raw string
+	db.DataSource().QueryRow("SELECT settings || tags || attrs FROM json_foos WHERE id = 1").Scan(&raw)
+	assert.Equal(`{"theme":"dark","size":2}["a","b"]{"k":"v"}`, raw, "Fields are stored as JSON text", t)
+	var n int
+	db.DataSource().QueryRow("SELECT COUNT(*) FROM json_foos WHERE tags IS NULL AND attrs IS NULL AND parent IS NULL").Scan(&n)
+	assert.Equal(1, n, "Nil maps, slices and pointers are stored as NULL", t)
+
+	var foos []*JSONFoo
+	op.Select("json_foos", &JSONFoo{}).Order("id").AllObjects(&foos)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("dark", foos[0].Settings.Theme, "Struct is unmarshalled", t)
+	assert.Equal("a,b", strings.Join(foos[0].Tags, ","), "Slice is unmarshalled", t)
+	assert.Equal("v", foos[0].Extra["k"], "Map is unmarshalled", t)
+	assert.True(foos[0].Parent == nil, "NULL pointer stays nil", t)
+
+	var foo = &JSONFoo{Tags: []string{"stale"}, Extra: map[string]string{"stale": "yes"}}
+	op.Select("json_foos", foo).Where("id = ?", 2).First(foo)
+	assert.True(foo.Tags == nil, "NULL resets a slice to nil", t)
+	assert.True(foo.Extra == nil, "NULL resets a map to nil", t)
+
+	foo = &JSONFoo{Extra: map[string]string{"stale": "yes"}}
+	op.Select("json_foos", foo).Where("id = ?", 1).First(foo)
+	assert.Equal(1, len(foo.Extra), "Scanning replaces a map rather than merging", t)
+}
+
+func TestJSONErrors(t *testing.T) {
+	var db = getdbJSON()
+	db.DataSource().Exec(`INSERT INTO json_foos (tags) VALUES ('{not json')`)
+
+	var op = db.Operation()
+	var foo = &JSONFoo{}
+	op.Select("json_foos", foo).First(foo)
+	var ce *ConversionError
+	assert.True(errors.As(op.Err(), &ce), fmt.Sprintf("Unmarshal failure (%s) is a ConversionError", op.Err()), t)
+	assert.Equal("tags", ce.Column, "Error names the column", t)
+	assert.Equal("JSONFoo.Tags", ce.Field, "Error names the field", t)
+
+	type Unmarshallable struct {
+		ID   int         `sql:",primary"`
+		Tags chan string `sql:",json"`
+	}
+	op = db.Operation()
+	op.Save("json_foos", &Unmarshallable{Tags: make(chan string)})
+	var oe *OpError
+	assert.True(errors.As(op.Err(), &oe) && oe.Phase == PhaseExec,
+		fmt.Sprintf("Marshal failure (%s) is reported by the Exec", op.Err()), t)
+}
+
+func TestJSONErrorSnapshot(t *testing.T) {
+	var db = getdbJSON()
+	db.DataSource().Exec("drop table json_foos")
+
+	var op = db.Operation()
+	var foo = &JSONFoo{Settings: Settings{"dark", 2}}
+	op.Save("json_foos", foo)
+	foo.Settings.Theme = "light"
+
+	var oe *OpError
+	if !errors.As(op.Err(), &oe) {
+		t.Log(op.Err())
+		t.FailNow()
+	}
+	var v, _ = oe.Args[0].(driver.Valuer).Value()
+	assert.Equal(`{"theme":"dark","size":2}`, v, "Error args hold the JSON as it was sent", t)
+}
@@ -0,0 +1,196 @@
+package magicsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryLogger writes a structured log record for every database call an
+// Operation makes.  Each record carries the call's kind, SQL, arguments,
+// duration, rows affected (for execs), error, and whether it ran inside a
+// transaction.
+//
+// Calls are logged at Level, except that failed calls are logged at
+// slog.LevelError and calls taking at least SlowThreshold (if non-zero) are
+// logged at slog.LevelWarn.
+type QueryLogger struct {
+	// Logger receives the records.  If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// Level is the level for ordinary calls; the zero value is slog.LevelInfo
+	Level slog.Level
+
+	// SlowThreshold, if non-zero, is the duration at which a call is
+	// considered slow
+	SlowThreshold time.Duration
+
+	// Interpolate adds a "query" attribute holding the SQL with its arguments
+	// substituted in, suitable for pasting into a database console.  This is
+	// for debugging only: it is not safe for executing.
+	Interpolate bool
+}
+
+// SetLogger sets the QueryLogger which every Operation created afterward
+// inherits.  nil turns logging off.
+func (db *DB) SetLogger(l *QueryLogger) {
+	db.mu.Lock()
+	db.logger = l
+	db.mu.Unlock()
+}
+
+// SetLogger overrides the QueryLogger for this Operation only.  nil turns
+// logging off.
+func (op *Operation) SetLogger(l *QueryLogger) {
+	op.logger = l
+}
+
+// log writes the record for a completed call
+func (l *QueryLogger) log(ctx context.Context, e *QueryEvent) {
+	var logger = l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var level = l.Level
+	var msg = "query"
+	switch {
+	case e.Err != nil:
+		level = slog.LevelError
+		msg = "query failed"
+	case l.SlowThreshold > 0 && e.Duration >= l.SlowThreshold:
+		level = slog.LevelWarn
+		msg = "slow query"
+	}
+
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	var attrs = []slog.Attr{
+		slog.String("kind", string(e.Kind)),
+		slog.String("sql", e.SQL),
+		slog.Any("args", stringifyArgs(e.Args...)),
+		slog.Duration("duration", e.Duration),
+		slog.Bool("tx", e.InTransaction),
+	}
+	if e.RowsAffected >= 0 {
+		attrs = append(attrs, slog.Int64("rows", e.RowsAffected))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	if l.Interpolate {
+		attrs = append(attrs, slog.String("query", Interpolate(e.SQL, e.Args)))
+	}
+
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// Interpolate returns query with each placeholder replaced by its argument
+// written as an SQL literal.  "?", "$n", "@pn", and ":n" placeholders are
+// recognized, and anything inside quotes is left alone.  The result is meant
+// for reading and copy-pasting while debugging; never execute it, as the
+// quoting is not guaranteed to be safe against every database's rules.
+func Interpolate(query string, args []interface{}) string {
+	var b strings.Builder
+	var next int
+	var quote byte
+
+	for i := 0; i < len(query); i++ {
+		var c = query[i]
+		if quote != 0 {
+			b.WriteByte(c)
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '?':
+			b.WriteString(interpolateArg(args, next))
+			next++
+			continue
+		case '$', '@', ':':
+			var start = i + 1
+			if c == '@' && start < len(query) && query[start] == 'p' {
+				start++
+			}
+			var end = start
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			if end > start {
+				var n, _ = strconv.Atoi(query[start:end])
+				b.WriteString(interpolateArg(args, n-1))
+				i = end - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// interpolateArg returns the SQL literal for args[i], or a "?" if there's no
+// such argument
+func interpolateArg(args []interface{}, i int) string {
+	if i < 0 || i >= len(args) {
+		return "?"
+	}
+	return sqlLiteral(args[i])
+}
+
+// sqlLiteral writes a single value as an SQL literal.  Blobs aren't read, as
+// that would leave nothing for the database, so they get stringify's
+// placeholder.
+func sqlLiteral(arg interface{}) string {
+	if arg == nil {
+		return "NULL"
+	}
+
+	var rv = reflect.ValueOf(arg)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return "NULL"
+	}
+	switch arg.(type) {
+	case Blob, *Blob:
+		return stringify(arg)
+	}
+	if v, ok := arg.(driver.Valuer); ok {
+		var dv, err = v.Value()
+		if err == nil {
+			return sqlLiteral(dv)
+		}
+	}
+	if rv.Kind() == reflect.Ptr {
+		return sqlLiteral(rv.Elem().Interface())
+	}
+
+	switch v := arg.(type) {
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999999-07:00") + "'"
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return stringify(arg)
+	case reflect.Bool:
+		return Generic.Bool(rv.Bool())
+	}
+
+	return "'" + strings.Replace(stringify(arg), "'", "''", -1) + "'"
+}
@@ -0,0 +1,81 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func logRecords(buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		json.Unmarshal([]byte(line), &rec)
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestQueryLogger(t *testing.T) {
+	var db = getdb()
+	var buf bytes.Buffer
+	db.SetLogger(&QueryLogger{
+		Logger:      slog.New(slog.NewJSONHandler(&buf, nil)),
+		Interpolate: true,
+	})
+
+	var op = db.Operation()
+	op.Save("foos", &Foo{ONE: "it's logged", Three: true, Four: 4})
+	op.Exec("INSERT INTO nonexistent VALUES (1)")
+
+	var records = logRecords(&buf)
+	assert.Equal(2, len(records), "Two records were logged", t)
+	assert.Equal("INFO", records[0]["level"], "Successful exec is logged at the default level", t)
+	assert.Equal("exec", records[0]["kind"], "Kind attribute", t)
+	assert.Equal(float64(1), records[0]["rows"], "Rows attribute", t)
+	assert.Equal(false, records[0]["tx"], "Transaction attribute", t)
+	assert.Equal("it's logged", records[0]["args"].([]interface{})[0], "Pointer args are rendered by value", t)
+	assert.Equal("INSERT INTO foos (one,tree,four,four_point_five) VALUES ('it''s logged',TRUE,4,0)",
+		records[0]["query"], "Interpolated query", t)
+
+	assert.Equal("ERROR", records[1]["level"], "Failed exec is logged as an error", t)
+	assert.True(strings.Contains(records[1]["error"].(string), "no such table"), "Error attribute", t)
+}
+
+func TestQueryLoggerSlow(t *testing.T) {
+	var db = getdb()
+	var buf bytes.Buffer
+	var op = db.Operation()
+	op.SetLogger(&QueryLogger{
+		Logger:        slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})),
+		SlowThreshold: time.Nanosecond,
+	})
+
+	op.BeginTransaction()
+	op.Query("SELECT one FROM foos").Close()
+	op.EndTransaction()
+
+	var records = logRecords(&buf)
+	assert.Equal(1, len(records), "Only the slow query was logged", t)
+	assert.Equal("WARN", records[0]["level"], "Slow query is logged as a warning", t)
+	assert.Equal("slow query", records[0]["msg"], "Slow query message", t)
+	assert.Equal(true, records[0]["tx"], "Query was in a transaction", t)
+}
+
+func TestInterpolate(t *testing.T) {
+	var when = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var n *int
+	assert.Equal("SELECT * FROM x WHERE a = 'b''c' AND d = NULL AND e = '2020-01-02 03:04:05+00:00' AND f = 'lit?'",
+		Interpolate("SELECT * FROM x WHERE a = ? AND d = ? AND e = ? AND f = 'lit?'", []interface{}{"b'c", n, when}),
+		"Question-mark placeholders", t)
+	assert.Equal("UPDATE x SET a = 2, b = X'0001' WHERE c = 1",
+		Interpolate("UPDATE x SET a = $2, b = @p3 WHERE c = :1", []interface{}{1, 2, []byte{0, 1}}),
+		"Numbered placeholders", t)
+}
@@ -1,93 +1,425 @@
 package magicsql
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 type boundField struct {
 	Name     string
+	Path     string // e.g. "Foo.Address.Street", for describing errors
 	Field    reflect.StructField
 	NoInsert bool
 	NoUpdate bool
+	JSON     bool
+
+	// TimeUnit and TimeLayout hold a time field's "unix", "unixms",
+	// "layout=..." and "date" options
+	TimeUnit   time.Duration
+	TimeLayout string
+}
+
+// timeFormat returns the format for reading and writing this field if it's a
+// time.Time (or pointer to one) which isn't using the defaults
+func (bf *boundField) timeFormat(conf fieldConfig) *timeFormat {
+	var ft = bf.Field.Type
+	if ft != timeType && (ft.Kind() != reflect.Ptr || ft.Elem() != timeType) {
+		return nil
+	}
+	return newTimeFormat(conf.times, bf.TimeUnit, bf.TimeLayout)
+}
+
+// fieldConfig holds the table- and DB-level settings which control how field
+// values are converted to and from column values
+type fieldConfig struct {
+	strict     bool
+	converters map[reflect.Type]Converter
+	times      *TimeConfig
+}
+
+// value returns the field within the structure v, following its index path
+// through any embedded or inlined pointers.  Nil pointers along the way are
+// allocated if alloc is true; otherwise ok is false when one is found.
+func (bf *boundField) value(v reflect.Value, alloc bool) (fv reflect.Value, ok bool) {
+	for i, x := range bf.Field.Index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// arg returns a pointer to the field within v for use as an Exec argument.
+// JSON fields are marshalled right away, and fields with a Converter or a
+// time format are converted by the driver.Valuer the arg wraps them in.
+// Pointer fields and fields implementing driver.Valuer are passed as-is, so
+// the driver always gets the Valuer itself.  The arg is nil (NULL) if the
+// field is a nil pointer or byte slice, or is inside a nil embedded or inlined
+// pointer.
+func (bf *boundField) arg(v reflect.Value, conf fieldConfig) interface{} {
+	var fv, ok = bf.value(v, false)
+	if !ok {
+		return nil
+	}
+	if bf.JSON {
+		return newJSONArg(fv)
+	}
+	if c, ok := lookupConverter(conf.converters, fv.Type()); ok && c.ToDB != nil {
+		return convertedArg{c.ToDB, fv.Interface()}
+	}
+	if tf := bf.timeFormat(conf); tf != nil {
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				return nil
+			}
+			fv = fv.Elem()
+		}
+		return timeArg{fv.Interface().(time.Time), tf}
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		if c, ok := lookupConverter(conf.converters, fv.Type().Elem()); ok && c.ToDB != nil {
+			return convertedArg{c.ToDB, fv.Elem().Interface()}
+		}
+		return fv.Interface()
+	}
+	if fv.Kind() == reflect.Slice && fv.IsNil() && fv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil
+	}
+	if fv.Type().Implements(valuerType) {
+		return fv.Interface()
+	}
+	return fv.Addr().Interface()
 }
 
 // MagicTable represents a named database table for reading data from a single
-// table into a tagged structure
+// table into a tagged structure.  Dialect controls the SQL generated by
+// InsertSQL and UpdateSQL, and defaults to Generic when nil.  When the table
+// is used through an Operation, the DB's dialect takes precedence.  Strict
+// turns on strict conversion (see NullableField) when scanning into this
+// table's structure, even if the DB doesn't use it.
+//
+// Naming and TagKeys are used by Configure: Naming derives column names for
+// fields without a name in their tag, and the table name if Name is empty,
+// defaulting to SnakeCase.  TagKeys lists the structure tag keys to read, in
+// order of preference, defaulting to just "sql".
 type MagicTable struct {
 	Object     interface{}
 	Name       string
 	RType      reflect.Type
+	Dialect    Dialect
+	Strict     bool
+	Naming     NamingStrategy
+	TagKeys    []string
 	sqlFields  []*boundField
 	primaryKey *boundField
+	statements *sync.Map
+
+	// derivedName is the name Configure last derived from the type, so it
+	// can tell a derived name from one the caller chose
+	derivedName string
 }
 
 // Table registers a table name and an object's type for use in database
 // operations.  The returned MagicTable is pre-configured using the object's
-// structure tags.
+// structure tags, and is the caller's to modify or reconfigure.  If name is
+// empty, the table is named after the object's type (see NamingStrategy).
 func Table(name string, obj interface{}) *MagicTable {
-	var mt = &MagicTable{Name: name, Object: obj}
-	mt.Configure(nil)
-	return mt
+	var ct = cachedTable(name, reflect.TypeOf(obj).Elem(), nil, nil, nil)
+	return &MagicTable{Name: ct.Name, Object: obj, RType: ct.RType, sqlFields: ct.sqlFields, primaryKey: ct.primaryKey,
+		derivedName: ct.derivedName}
 }
 
 // Configure traverses the wrapped structure to figure out which fields map to
 // database table columns and how.  If conf is non-nil, that is used in place
 // of struct tags.  This is run if a table is created with Table(), but can be
 // useful for reconfiguring a table with explicit ConfigTags.
+//
+// Anonymous embedded structures (or pointers to them) are flattened, so their
+// fields map to columns as if they were declared in the outer structure.
+// Go's shadowing rules apply: a shallower field hides any deeper field of the
+// same name, and fields which are ambiguous at the same depth are skipped.
+// Tag an embedded structure with "noembed" to map it as a single column
+// instead, or "-" to skip it.  time.Time and types which implement
+// sql.Scanner or driver.Valuer are never flattened.
+//
+// A named structure field (or pointer to one) tagged "inline" is mapped the
+// same way, but its columns are prefixed with the tag's name, or the field's
+// column name when the tag has no name.  The naming strategy joins the prefix
+// to each column name, with an underscore unless it implements PrefixJoiner.
+// Inlining nests, so an inlined structure's inlined fields get both prefixes.
+// When using ConfigTags, fields within an inlined structure are keyed by
+// their path, such as "Address.Street".
+//
+// A field tagged "json" is stored as JSON text: it's marshalled when inserted
+// or updated, and unmarshalled when scanned, with NULL mapping to the zero
+// value (nil for maps and slices).  This is meant for structures, maps and
+// slices, which are never flattened or inlined when tagged "json".
+//
+// time.Time fields may be tagged "unix" or "unixms" to store them as integer
+// seconds or milliseconds since the epoch, "layout=..." to store them as text
+// in a specific layout, or "date" as shorthand for "layout=2006-01-02".  Since
+// layouts may contain commas (as time.RFC1123 does), "layout=..." must be the
+// last option: everything after "layout=" is the layout.  See
+// DB.SetTimeConfig for the settings which apply to all time fields.
 func (t *MagicTable) Configure(conf ConfigTags) {
 	t.sqlFields = nil
+	t.primaryKey = nil
 	t.RType = reflect.TypeOf(t.Object).Elem()
-	var rVal = reflect.ValueOf(t.Object).Elem()
 
-	for i := 0; i < t.RType.NumField(); i++ {
-		var sf = t.RType.Field(i)
+	var naming = t.naming()
+	if t.Name == "" || t.Name == t.derivedName {
+		t.Name = naming.TableName(t.RType.Name())
+		t.derivedName = t.Name
+	}
 
-		if !rVal.Field(i).CanSet() {
+	var w = &fieldWalker{conf: conf, naming: naming, tagKeys: t.tagKeys(), seen: map[reflect.Type]bool{}}
+	for _, f := range visibleFields(w.collect(t.RType, nil, "", "")) {
+		if f.embedded {
 			continue
 		}
 
+		var sqlf = strings.Split(f.tag, ",")[0]
+		if sqlf == "" {
+			sqlf = naming.ColumnName(f.sf.Name)
+		}
+		sqlf = joinPrefix(naming, f.prefix, sqlf)
+
+		var bf = &boundField{Name: sqlf, Path: t.RType.Name() + "." + f.name, Field: f.sf}
+		t.sqlFields = append(t.sqlFields, bf)
+
+		for _, part := range tagOptions(f.tag) {
+			switch part {
+			case "primary":
+				if t.primaryKey == nil {
+					bf.NoInsert = true
+					bf.NoUpdate = true
+					t.primaryKey = bf
+				}
+			case "readonly":
+				bf.NoInsert = true
+				bf.NoUpdate = true
+			case "noinsert":
+				bf.NoInsert = true
+			case "noupdate":
+				bf.NoUpdate = true
+			case "json":
+				bf.JSON = true
+			case "unix":
+				bf.TimeUnit = time.Second
+			case "unixms":
+				bf.TimeUnit = time.Millisecond
+			case "date":
+				bf.TimeLayout = "2006-01-02"
+			default:
+				if strings.HasPrefix(part, "layout=") {
+					bf.TimeLayout = strings.TrimPrefix(part, "layout=")
+				}
+			}
+		}
+	}
+}
+
+// structField is a field found while walking a structure.  sf.Index is the
+// full path from the outermost structure, and name is the field's name as seen
+// from there, qualified by any inlined structures it's within.  Flattened
+// embedded structures are included with embedded set, since their names can
+// shadow deeper fields, but they don't map to a column.
+type structField struct {
+	sf       reflect.StructField
+	name     string
+	tag      string
+	prefix   string
+	depth    int
+	embedded bool
+}
+
+// naming returns the table's NamingStrategy, falling back to SnakeCase
+func (t *MagicTable) naming() NamingStrategy {
+	if t.Naming == nil {
+		return SnakeCase
+	}
+	return t.Naming
+}
+
+// tagKeys returns the table's TagKeys, falling back to "sql"
+func (t *MagicTable) tagKeys() []string {
+	if len(t.TagKeys) == 0 {
+		return defaultTagKeys
+	}
+	return t.TagKeys
+}
+
+var defaultTagKeys = []string{"sql"}
+
+// fieldWalker gathers the fields of a structure for Configure
+type fieldWalker struct {
+	conf    ConfigTags
+	naming  NamingStrategy
+	tagKeys []string
+	seen    map[reflect.Type]bool
+}
+
+// collect returns rtype's fields in declaration order, with the fields of
+// flattened embedded structures and inlined structures following the
+// structure itself.  Fields which can't be set and fields tagged "-" are
+// skipped.  path qualifies field names (and ConfigTags keys) within inlined
+// structures, and prefix is joined to their column names.
+func (w *fieldWalker) collect(rtype reflect.Type, index []int, path, prefix string) []structField {
+	w.seen[rtype] = true
+	defer delete(w.seen, rtype)
+
+	var fields []structField
+	for i := 0; i < rtype.NumField(); i++ {
+		var sf = rtype.Field(i)
+		sf.Index = append(append([]int(nil), index...), i)
+
+		var name = path + sf.Name
 		var tag string
-		if conf == nil {
-			tag = sf.Tag.Get("sql")
+		if w.conf == nil {
+			tag = lookupTag(sf.Tag, w.tagKeys)
 		} else {
-			tag = conf[sf.Name]
+			tag = w.conf[name]
 		}
-
 		if tag == "-" {
 			continue
 		}
 
-		var parts = strings.Split(tag, ",")
-		var sqlf = parts[0]
-		if sqlf == "" {
-			sqlf = toUnderscore(sf.Name)
+		var st = sf.Type
+		var isPtr = st.Kind() == reflect.Ptr
+		if isPtr {
+			st = st.Elem()
 		}
+		var f = structField{sf: sf, name: name, tag: tag, prefix: prefix, depth: len(index)}
 
-		var bf = &boundField{sqlf, sf, false, false}
-		t.sqlFields = append(t.sqlFields, bf)
+		if sf.Anonymous && flattenable(st, tag) && !w.seen[st] {
+			// An unexported pointer can't be allocated, so its fields can't be
+			// set; an unexported struct value's exported fields can be
+			if isPtr && !sf.IsExported() {
+				continue
+			}
+			f.embedded = true
+			fields = append(fields, f)
+			fields = append(fields, w.collect(st, sf.Index, path, prefix)...)
+			continue
+		}
 
-		if len(parts) > 1 {
-			for _, part := range parts[1:] {
-				switch part {
-				case "primary":
-					if t.primaryKey == nil {
-						bf.NoInsert = true
-						bf.NoUpdate = true
-						t.primaryKey = bf
-					}
-				case "readonly":
-					bf.NoInsert = true
-					bf.NoUpdate = true
-				case "noinsert":
-					bf.NoInsert = true
-				case "noupdate":
-					bf.NoUpdate = true
-				}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if inlined(st, tag) && !w.seen[st] {
+			var parts = strings.Split(tag, ",")
+			var base = parts[0]
+			if base == "" {
+				base = w.naming.ColumnName(sf.Name)
 			}
+			fields = append(fields, w.collect(st, sf.Index, name+".", joinPrefix(w.naming, prefix, base))...)
+			continue
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// composite returns true if rtype is a structure whose fields can be mapped
+// to columns, rather than a value the database driver handles on its own
+func composite(rtype reflect.Type) bool {
+	if rtype.Kind() != reflect.Struct || rtype == timeType {
+		return false
+	}
+	return !reflect.PtrTo(rtype).Implements(scannerType) && !rtype.Implements(valuerType)
+}
+
+// flattenable returns true if an embedded field of type rtype (already
+// dereferenced if it was a pointer) and the given tag should contribute its
+// fields to the outer structure.  Naming the field in its tag maps it as a
+// single column, just like the "noembed" option.
+func flattenable(rtype reflect.Type, tag string) bool {
+	if !composite(rtype) {
+		return false
+	}
+
+	if strings.Split(tag, ",")[0] != "" {
+		return false
+	}
+	return !tagHas(tag, "noembed") && !tagHas(tag, "json")
+}
+
+// inlined returns true if a named field of type rtype (already dereferenced
+// if it was a pointer) is tagged to have its fields mapped as prefixed columns
+func inlined(rtype reflect.Type, tag string) bool {
+	return composite(rtype) && tagHas(tag, "inline") && !tagHas(tag, "json")
+}
+
+// tagHas returns true if the tag lists the given option after its name
+func tagHas(tag, option string) bool {
+	for _, part := range tagOptions(tag) {
+		if part == option {
+			return true
+		}
+	}
+	return false
+}
+
+// tagOptions returns the comma-separated options after a tag's name.  A
+// "layout=" option takes the rest of the tag, commas and all.
+func tagOptions(tag string) []string {
+	var parts = strings.Split(tag, ",")[1:]
+	for i, part := range parts {
+		if strings.HasPrefix(part, "layout=") {
+			return append(parts[:i], strings.Join(parts[i:], ","))
 		}
 	}
+	return parts
+}
+
+// visibleFields applies Go's shadowing rules to fields: of all fields sharing
+// a name, only the shallowest is kept, and if more than one field has that
+// depth, the name is ambiguous and none of them are kept
+func visibleFields(fields []structField) []structField {
+	var shallowest = make(map[string]int)
+	var count = make(map[string]int)
+	for _, f := range fields {
+		var d, ok = shallowest[f.name]
+		switch {
+		case !ok || f.depth < d:
+			shallowest[f.name] = f.depth
+			count[f.name] = 1
+		case f.depth == d:
+			count[f.name]++
+		}
+	}
+
+	var visible []structField
+	for _, f := range fields {
+		if f.depth == shallowest[f.name] && count[f.name] == 1 {
+			visible = append(visible, f)
+		}
+	}
+	return visible
 }
 
 // FieldNames returns all known table field names based on the tag parsing done
@@ -102,38 +434,85 @@ func (t *MagicTable) FieldNames() []string {
 
 // ScanStruct sets up a structure suitable for calling Scan to populate dest
 func (t *MagicTable) ScanStruct(dest interface{}) []interface{} {
+	return t.scanStruct(dest, fieldConfig{strict: t.Strict})
+}
+
+func (t *MagicTable) scanStruct(dest interface{}, conf fieldConfig) []interface{} {
 	var fields = make([]interface{}, len(t.sqlFields))
 	var rVal = reflect.ValueOf(dest).Elem()
 	for i, bf := range t.sqlFields {
-		var vf = rVal.FieldByName(bf.Field.Name)
-		fields[i] = &NullableField{Value: vf.Addr().Interface()}
+		var vf, _ = bf.value(rVal, true)
+		if bf.JSON {
+			fields[i] = &jsonField{v: vf, column: bf.Name, field: bf.Path}
+			continue
+		}
+
+		fields[i] = &NullableField{
+			Value:      vf.Addr().Interface(),
+			Strict:     conf.strict,
+			Column:     bf.Name,
+			Field:      bf.Path,
+			converters: conf.converters,
+			timeFormat: bf.timeFormat(conf),
+		}
 	}
 
 	return fields
 }
 
+// dialect returns the table's Dialect, falling back to Generic
+func (t *MagicTable) dialect() Dialect {
+	if t.Dialect == nil {
+		return Generic
+	}
+	return t.Dialect
+}
+
 // InsertSQL returns the SQL string for inserting a record into this table.
 // This makes the assumption that the primary key is not being set, so it isn't
 // part of the fields list of values placeholder.
 func (t *MagicTable) InsertSQL() string {
+	return t.insertSQL(t.dialect())
+}
+
+func (t *MagicTable) insertSQL(d Dialect) string {
+	return t.sqlFor(d).insert
+}
+
+// insertReturningSQL returns the SQL for inserting a record and reading back
+// its primary key, or an empty string if d can't do that (or there's no key)
+func (t *MagicTable) insertReturningSQL(d Dialect) string {
+	return t.sqlFor(d).insertReturning
+}
+
+func (t *MagicTable) buildInsertSQL(d Dialect) (insert, returning string) {
 	var fList []string
 	var qList []string
 	for _, bf := range t.sqlFields {
 		if bf.NoInsert {
 			continue
 		}
-		fList = append(fList, bf.Name)
-		qList = append(qList, "?")
+		fList = append(fList, d.Quote(bf.Name))
+		qList = append(qList, d.Placeholder(len(qList)+1))
 	}
 
+	var name = quoteName(d, t.Name)
 	var fields = strings.Join(fList, ",")
 	var placeholders = strings.Join(qList, ",")
-	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.Name, fields, placeholders)
+	insert = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", name, fields, placeholders)
+	if rd, ok := d.(ReturningDialect); ok && t.primaryKey != nil {
+		returning = rd.InsertReturning(name, fields, placeholders, d.Quote(t.primaryKey.Name))
+	}
+	return insert, returning
 }
 
 // InsertArgs sets up and returns an array suitable for passing to an SQL Exec
 // call for doing an insert
 func (t *MagicTable) InsertArgs(source interface{}) []interface{} {
+	return t.insertArgs(source, fieldConfig{})
+}
+
+func (t *MagicTable) insertArgs(source interface{}, conf fieldConfig) []interface{} {
 	var save []interface{}
 	var rVal = reflect.ValueOf(source).Elem()
 
@@ -142,8 +521,7 @@ func (t *MagicTable) InsertArgs(source interface{}) []interface{} {
 		if bf.NoInsert {
 			continue
 		}
-		var vf = rVal.FieldByName(bf.Field.Name)
-		save = append(save, vf.Addr().Interface())
+		save = append(save, bf.arg(rVal, conf))
 	}
 
 	return save
@@ -152,6 +530,14 @@ func (t *MagicTable) InsertArgs(source interface{}) []interface{} {
 // UpdateSQL returns the SQL string for updating a record in this table.
 // Returns an empty string if there's no primary key.
 func (t *MagicTable) UpdateSQL() string {
+	return t.updateSQL(t.dialect())
+}
+
+func (t *MagicTable) updateSQL(d Dialect) string {
+	return t.sqlFor(d).update
+}
+
+func (t *MagicTable) buildUpdateSQL(d Dialect) string {
 	if t.primaryKey == nil {
 		return ""
 	}
@@ -161,16 +547,21 @@ func (t *MagicTable) UpdateSQL() string {
 		if bf.NoUpdate {
 			continue
 		}
-		setList = append(setList, fmt.Sprintf("%s = ?", bf.Name))
+		setList = append(setList, fmt.Sprintf("%s = %s", d.Quote(bf.Name), d.Placeholder(len(setList)+1)))
 	}
 	var sets = strings.Join(setList, ",")
 
-	return fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", t.Name, sets, t.primaryKey.Name)
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s", quoteName(d, t.Name), sets,
+		d.Quote(t.primaryKey.Name), d.Placeholder(len(setList)+1))
 }
 
 // UpdateArgs sets up and returns an array suitable for passing to an SQL Exec
 // call for doing an update.  Returns nil if there's no primary key.
 func (t *MagicTable) UpdateArgs(source interface{}) []interface{} {
+	return t.updateArgs(source, fieldConfig{})
+}
+
+func (t *MagicTable) updateArgs(source interface{}, conf fieldConfig) []interface{} {
 	if t.primaryKey == nil {
 		return nil
 	}
@@ -182,10 +573,9 @@ func (t *MagicTable) UpdateArgs(source interface{}) []interface{} {
 		if bf.NoUpdate {
 			continue
 		}
-		var vf = rVal.FieldByName(bf.Field.Name)
-		save = append(save, vf.Addr().Interface())
+		save = append(save, bf.arg(rVal, conf))
 	}
 
-	save = append(save, rVal.FieldByName(t.primaryKey.Field.Name).Addr().Interface())
+	save = append(save, t.primaryKey.arg(rVal, conf))
 	return save
 }
@@ -1,8 +1,10 @@
 package magicsql
 
 import (
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Nerdmaster/magicsql/assert"
 )
@@ -96,3 +98,170 @@ func TestNoUpdateTag(t *testing.T) {
 	assert.Equal("I'll be there on update!", *save[0].(*string), "Arg 1 is the insert-only arg", t)
 	assert.Equal(0, *save[1].(*int), "Arg 2 is the id", t)
 }
+
+type BaseModel struct {
+	ID    int `sql:",primary"`
+	Notes string
+}
+
+type Timestamps struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time `sql:"modified_at"`
+}
+
+type EmbedFoo struct {
+	BaseModel
+	*Timestamps
+	Name  string
+	Notes string `sql:"remarks"`
+}
+
+func TestEmbeddedFields(t *testing.T) {
+	var table = Table("embed_foos", &EmbedFoo{})
+	assert.Equal("id,created_at,modified_at,name,remarks", strings.Join(table.FieldNames(), ","),
+		"Embedded fields are flattened in place, and Notes is shadowed by the outer field", t)
+	assert.Equal("UPDATE embed_foos SET created_at = ?,modified_at = ?,name = ?,remarks = ? WHERE id = ?",
+		table.UpdateSQL(), "The embedded primary key is used", t)
+
+	var foo = &EmbedFoo{Name: "foo"}
+	foo.ID = 5
+	var args = table.UpdateArgs(foo)
+	assert.True(args[0] == nil, "A nil embedded pointer's fields are NULL", t)
+	assert.Equal(5, *args[4].(*int), "The embedded primary key is the last arg", t)
+
+	var scan = table.ScanStruct(foo)
+	*scan[1].(*NullableField).Value.(*time.Time) = time.Unix(1, 0)
+	assert.True(foo.Timestamps != nil, "Scanning allocates nil embedded pointers", t)
+	assert.Equal(int64(1), foo.CreatedAt.Unix(), "Scanning writes through the embedded pointer", t)
+}
+
+type left struct {
+	Shared string
+	Left   string
+}
+
+type right struct {
+	Shared string
+	Right  string
+}
+
+type EmbedOptOut struct {
+	left
+	right
+	Timestamps `sql:",noembed"`
+	When       time.Time
+	BaseModel  `sql:"-"`
+}
+
+func TestEmbeddedAmbiguityAndOptOut(t *testing.T) {
+	var table = Table("opt_outs", &EmbedOptOut{})
+	assert.Equal("left,right,timestamps,when", strings.Join(table.FieldNames(), ","),
+		"Ambiguous fields are dropped, unexported embeds still contribute, and opted-out structs aren't flattened", t)
+	assert.True(table.primaryKey == nil, "Skipped embeds don't contribute a primary key", t)
+}
+
+func TestEmbeddedSave(t *testing.T) {
+	var db = getdb()
+	db.DataSource().Exec(`
+		drop table if exists embed_foos;
+		create table embed_foos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at datetime,
+			modified_at datetime,
+			name text,
+			remarks text
+		);
+	`)
+
+	var op = db.Operation()
+	var foo = &EmbedFoo{Name: "foo", Notes: "note", Timestamps: &Timestamps{CreatedAt: time.Unix(100, 0).UTC()}}
+	op.Save("embed_foos", foo)
+	assert.Equal(1, foo.ID, "Save set the embedded primary key", t)
+
+	var found = &EmbedFoo{}
+	assert.True(op.Find("embed_foos", found, 1), "Find the saved record", t)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("note", found.Notes, "Outer field read", t)
+	assert.Equal(int64(100), found.CreatedAt.Unix(), "Embedded pointer field read", t)
+}
+
+type Money struct {
+	Amount   int
+	Currency string `sql:"cur"`
+}
+
+type Address struct {
+	Street string
+	City   string
+	Geo    *struct {
+		Lat float64
+		Lng float64
+	} `sql:",inline"`
+}
+
+type InlineFoo struct {
+	ID      int     `sql:",primary"`
+	Address Address `sql:",inline"`
+	Billing *Money  `sql:"bill,inline"`
+	City    string
+}
+
+func TestInlineFields(t *testing.T) {
+	var table = Table("inline_foos", &InlineFoo{})
+	assert.Equal("id,address_street,address_city,address_geo_lat,address_geo_lng,bill_amount,bill_cur,city",
+		strings.Join(table.FieldNames(), ","), "Inlined fields are prefixed, and don't shadow outer fields", t)
+
+	var foo = &InlineFoo{Address: Address{Street: "1 Main", City: "Springfield"}}
+	var args = table.InsertArgs(foo)
+	assert.Equal("1 Main", *args[0].(*string), "Inlined value fields are read through their path", t)
+	assert.True(args[2] == nil, "Fields inside a nil inlined pointer are NULL", t)
+	assert.True(args[4] == nil, "Fields inside a nil inlined pointer are NULL", t)
+
+	var scan = table.ScanStruct(foo)
+	*scan[5].(*NullableField).Value.(*int) = 250
+	assert.True(foo.Billing != nil, "Scanning allocates nil inlined pointers", t)
+	assert.Equal(250, foo.Billing.Amount, "Scanning writes through the inlined pointer", t)
+}
+
+func TestInlineConfigTags(t *testing.T) {
+	var table = Table("inline_foos", &InlineFoo{})
+	table.Configure(ConfigTags{
+		"ID":             ",primary",
+		"Address":        "addr,inline",
+		"Address.Street": "line1",
+		"Address.Geo":    "-",
+		"Billing":        "-",
+	})
+	assert.Equal("id,addr_line1,addr_city,city", strings.Join(table.FieldNames(), ","),
+		"ConfigTags address inlined fields by path", t)
+}
+
+func TestInlineSave(t *testing.T) {
+	var db = getdb()
+	db.DataSource().Exec(`
+		drop table if exists inline_foos;
+		create table inline_foos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			address_street text,
+			address_city text,
+			address_geo_lat real,
+			address_geo_lng real,
+			bill_amount int,
+			bill_cur text,
+			city text
+		);
+	`)
+
+	var op = db.Operation()
+	var foo = &InlineFoo{Address: Address{Street: "1 Main", City: "Springfield"}, Billing: &Money{250, "USD"}}
+	op.Save("inline_foos", foo)
+	foo.Billing.Amount = 300
+	op.Save("inline_foos", foo)
+
+	var found = &InlineFoo{}
+	assert.True(op.Find("inline_foos", found, foo.ID), "Find the saved record", t)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("Springfield", found.Address.City, "Inlined value field read", t)
+	assert.Equal(300, found.Billing.Amount, "Inlined pointer field updated and read", t)
+	assert.Equal("USD", found.Billing.Currency, "Inlined pointer field read", t)
+}
@@ -0,0 +1,75 @@
+package magicsqltest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nerdmaster/magicsql"
+)
+
+// update is set by running tests with -magicsqltest.update, or by setting
+// MAGICSQLTEST_UPDATE=1 in the environment
+var update = flag.Bool("magicsqltest.update", false, "rewrite magicsqltest golden files")
+
+func updating() bool {
+	return *update || os.Getenv("MAGICSQLTEST_UPDATE") == "1"
+}
+
+// AssertGolden compares got to the contents of the golden file at path,
+// failing the test if they differ.  When tests are run with
+// -magicsqltest.update (or MAGICSQLTEST_UPDATE=1), the golden file is
+// rewritten with got instead, so SQL changes show up as diffs in code review.
+func AssertGolden(t TB, path string, got string) {
+	t.Helper()
+
+	if updating() {
+		var err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(got), 0644)
+		}
+		if err != nil {
+			t.Errorf("magicsqltest: unable to write golden file %s: %s", path, err)
+		}
+		return
+	}
+
+	var want, err = os.ReadFile(path)
+	if err != nil {
+		t.Errorf("magicsqltest: unable to read golden file %s (run tests with -magicsqltest.update to create it): %s", path, err)
+		return
+	}
+
+	if string(want) != got {
+		t.Errorf("magicsqltest: SQL doesn't match golden file %s (run tests with -magicsqltest.update to accept it)\n%s",
+			path, firstDifference(string(want), got))
+	}
+}
+
+// AssertRecording compares a Recording's transcript to a golden file; see
+// AssertGolden
+func AssertRecording(t TB, path string, r *magicsql.Recording) {
+	t.Helper()
+	AssertGolden(t, path, r.Transcript())
+}
+
+// firstDifference describes the first line at which want and got differ
+func firstDifference(want, got string) string {
+	var wl = strings.Split(want, "\n")
+	var gl = strings.Split(got, "\n")
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
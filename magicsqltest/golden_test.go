@@ -0,0 +1,63 @@
+package magicsqltest_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+	"github.com/Nerdmaster/magicsql/magicsqltest"
+)
+
+// fakeTB captures test failures so we can check that mismatches are reported
+type fakeTB struct {
+	errors []string
+}
+
+func (f *fakeTB) Helper() {}
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestGoldenRecording(t *testing.T) {
+	var db, mock = magicsqltest.New()
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExecRegexp("^INSERT").WillReturnResult(1, 1)
+	mock.ExpectExecRegexp("^UPDATE")
+	mock.ExpectQueryRegexp("^SELECT")
+
+	var rec = db.Record()
+	var op = db.Operation()
+	var p = &Person{Name: "Pat O'Brien", Age: 40}
+	op.Save("people", p)
+	p.Age = 41
+	op.Save("people", p)
+	op.Select("people", &Person{}).Where("age > ?", 30).Order("name").Limit(5).First(&Person{})
+	rec.Stop()
+	op.Select("people", &Person{}).First(&Person{})
+
+	assert.Equal(3, len(rec.Statements()), "Statements after Stop aren't recorded", t)
+	magicsqltest.AssertRecording(t, filepath.Join("testdata", "people.golden"), rec)
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	if flag.Lookup("magicsqltest.update").Value.String() == "true" {
+		t.Skip("golden files are being updated")
+	}
+
+	var path = filepath.Join(t.TempDir(), "mismatch.golden")
+	os.WriteFile(path, []byte("exec: DELETE FROM people\n"), 0644)
+
+	var tb = &fakeTB{}
+	magicsqltest.AssertGolden(tb, path, "query: SELECT 1\n")
+	assert.Equal(1, len(tb.errors), "Mismatch is reported", t)
+	assert.True(strings.Contains(tb.errors[0], "line 1:\n  want: exec: DELETE FROM people\n  got:  query: SELECT 1"),
+		"First differing line is shown", t)
+
+	tb = &fakeTB{}
+	magicsqltest.AssertGolden(tb, filepath.Join(t.TempDir(), "missing.golden"), "")
+	assert.Equal(1, len(tb.errors), "Missing golden file is reported", t)
+}
@@ -0,0 +1,318 @@
+// Package magicsqltest provides an in-process database/sql driver for testing
+// code built on magicsql without a real database.  Tests register the SQL they
+// expect to run, along with the arguments it should receive and the rows,
+// results, or errors it should produce.  Once the code under test has run,
+// ExpectationsWereMet reports any expectations which weren't met and any
+// calls nobody expected.
+//
+// Expectations are matched in the order they were registered unless
+// MatchExpectationsInOrder(false) is called.  Prepared statements don't need
+// expectations of their own: the statement's Exec or Query is matched when it
+// runs.
+package magicsqltest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Nerdmaster/magicsql"
+)
+
+type kind string
+
+const (
+	kindQuery    kind = "query"
+	kindExec     kind = "exec"
+	kindBegin    kind = "begin"
+	kindCommit   kind = "commit"
+	kindRollback kind = "rollback"
+)
+
+// Mock holds the expectations for a single fake database
+type Mock struct {
+	mu         sync.Mutex
+	expected   []*Expectation
+	unexpected []string
+	unordered  bool
+}
+
+// New returns a magicsql.DB backed by a fresh fake database, and the Mock used
+// to set up its expectations
+func New() (*magicsql.DB, *Mock) {
+	var m = &Mock{}
+	return magicsql.Wrap(sql.OpenDB(connector{m})), m
+}
+
+// MatchExpectationsInOrder controls whether calls must happen in the order
+// their expectations were registered (the default) or may match any
+// unfulfilled expectation
+func (m *Mock) MatchExpectationsInOrder(ordered bool) {
+	m.mu.Lock()
+	m.unordered = !ordered
+	m.mu.Unlock()
+}
+
+func (m *Mock) expect(k kind, sql string, re *regexp.Regexp) *Expectation {
+	var e = &Expectation{kind: k, sql: normalize(sql), re: re, rowsAffected: 1}
+	m.mu.Lock()
+	m.expected = append(m.expected, e)
+	m.mu.Unlock()
+	return e
+}
+
+// ExpectQuery registers a query whose SQL must match sql exactly, ignoring
+// differences in whitespace
+func (m *Mock) ExpectQuery(sql string) *Expectation {
+	return m.expect(kindQuery, sql, nil)
+}
+
+// ExpectQueryRegexp registers a query whose SQL must match the regular
+// expression pattern
+func (m *Mock) ExpectQueryRegexp(pattern string) *Expectation {
+	return m.expect(kindQuery, "", regexp.MustCompile(pattern))
+}
+
+// ExpectExec registers a statement whose SQL must match sql exactly, ignoring
+// differences in whitespace
+func (m *Mock) ExpectExec(sql string) *Expectation {
+	return m.expect(kindExec, sql, nil)
+}
+
+// ExpectExecRegexp registers a statement whose SQL must match the regular
+// expression pattern
+func (m *Mock) ExpectExecRegexp(pattern string) *Expectation {
+	return m.expect(kindExec, "", regexp.MustCompile(pattern))
+}
+
+// ExpectBegin registers the start of a transaction
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(kindBegin, "", nil)
+}
+
+// ExpectCommit registers a transaction commit
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(kindCommit, "", nil)
+}
+
+// ExpectRollback registers a transaction rollback
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(kindRollback, "", nil)
+}
+
+// ExpectationsWereMet returns an error describing every expectation which
+// wasn't fulfilled and every call which didn't match an expectation, or nil
+// if everything went as expected
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var problems []string
+	for _, e := range m.expected {
+		if !e.fulfilled {
+			problems = append(problems, "expected but not run: "+e.String())
+		}
+	}
+	for _, u := range m.unexpected {
+		problems = append(problems, "unexpected: "+u)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("magicsqltest: %s", strings.Join(problems, "; "))
+}
+
+// TB is the subset of testing.TB used by AssertExpectations
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertExpectations fails the test if ExpectationsWereMet returns an error
+func (m *Mock) AssertExpectations(t TB) {
+	t.Helper()
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+// match finds and fulfills the expectation for a call, or records the call as
+// unexpected and returns an error
+func (m *Mock) match(k kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var values = make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+
+	for _, e := range m.expected {
+		if e.fulfilled {
+			continue
+		}
+		if e.matches(k, query, values) {
+			e.fulfilled = true
+			return e, nil
+		}
+		if !m.unordered {
+			break
+		}
+	}
+
+	var desc = string(k)
+	if query != "" {
+		desc = fmt.Sprintf("%s %q with args %v", k, query, values)
+	}
+	m.unexpected = append(m.unexpected, desc)
+	return nil, fmt.Errorf("magicsqltest: unexpected %s", desc)
+}
+
+// normalize collapses all whitespace runs so exact matches aren't thrown off
+// by indentation or line breaks
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Argument can be passed to WithArgs to match an argument by something other
+// than equality
+type Argument interface {
+	Match(driver.Value) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(driver.Value) bool { return true }
+
+// AnyArg returns an Argument which matches any value
+func AnyArg() Argument {
+	return anyArg{}
+}
+
+// Expectation is a single expected call and the response it should produce
+type Expectation struct {
+	kind      kind
+	sql       string
+	re        *regexp.Regexp
+	args      []interface{}
+	checkArgs bool
+	fulfilled bool
+
+	rows         *Rows
+	lastInsertID int64
+	rowsAffected int64
+	err          error
+}
+
+// WithArgs requires the call's arguments to match args.  Each value is
+// converted the same way database/sql converts arguments (so an int matches
+// the int64 the driver receives, and a pointer matches the value it points
+// to), unless it's an Argument.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = args
+	e.checkArgs = true
+	return e
+}
+
+// WillReturnRows sets the rows a query produces
+func (e *Expectation) WillReturnRows(r *Rows) *Expectation {
+	e.rows = r
+	return e
+}
+
+// WillReturnResult sets the last insert id and rows affected an exec
+// produces.  Without this, an exec reports an id of 0 and 1 row affected.
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.lastInsertID = lastInsertID
+	e.rowsAffected = rowsAffected
+	return e
+}
+
+// WillReturnError makes the call fail with err
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// String describes the expectation for error messages
+func (e *Expectation) String() string {
+	var s = string(e.kind)
+	switch {
+	case e.re != nil:
+		s += fmt.Sprintf(" matching %q", e.re.String())
+	case e.sql != "":
+		s += fmt.Sprintf(" %q", e.sql)
+	}
+	if e.checkArgs {
+		s += fmt.Sprintf(" with args %v", e.args)
+	}
+	return s
+}
+
+func (e *Expectation) matches(k kind, query string, values []driver.Value) bool {
+	if e.kind != k {
+		return false
+	}
+	if e.re != nil && !e.re.MatchString(query) {
+		return false
+	}
+	if e.re == nil && e.sql != normalize(query) {
+		return false
+	}
+	if !e.checkArgs {
+		return true
+	}
+
+	if len(e.args) != len(values) {
+		return false
+	}
+	for i, arg := range e.args {
+		if a, ok := arg.(Argument); ok {
+			if !a.Match(values[i]) {
+				return false
+			}
+			continue
+		}
+		var want, err = driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil || !reflect.DeepEqual(want, values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rows holds the canned rows a query expectation returns
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// NewRows starts a set of rows with the given column names
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row.  Values are converted as database/sql converts
+// arguments, so they look like what a real driver would return.  AddRow
+// panics if the number of values doesn't match the number of columns.
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("magicsqltest: row has %d values for %d columns", len(values), len(r.columns)))
+	}
+
+	var row = make([]driver.Value, len(values))
+	for i, v := range values {
+		var dv, err = driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			panic(fmt.Sprintf("magicsqltest: invalid row value %#v: %s", v, err))
+		}
+		row[i] = dv
+	}
+	r.values = append(r.values, row)
+	return r
+}
@@ -0,0 +1,78 @@
+package magicsqltest_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+	"github.com/Nerdmaster/magicsql/magicsqltest"
+)
+
+type Person struct {
+	ID   int `sql:",primary"`
+	Name string
+	Age  int
+}
+
+func TestSaveAndSelect(t *testing.T) {
+	var db, mock = magicsqltest.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO people (name,age) VALUES (?,?)").WithArgs("Pat", 40).WillReturnResult(7, 1)
+	mock.ExpectExec("UPDATE people SET name = ?,age = ? WHERE id = ?").WithArgs("Pat", magicsqltest.AnyArg(), 7)
+	mock.ExpectCommit()
+	mock.ExpectQueryRegexp(`^SELECT id,name,age FROM people WHERE age > \?`).WithArgs(30).
+		WillReturnRows(magicsqltest.NewRows("id", "name", "age").AddRow(7, "Pat", 41).AddRow(8, "Sam", 35))
+
+	var op = db.Operation()
+	var p = &Person{Name: "Pat", Age: 40}
+	op.BeginTransaction()
+	op.Save("people", p)
+	assert.Equal(7, p.ID, "Primary key comes from the mocked result", t)
+	p.Age = 41
+	op.Save("people", p)
+	op.EndTransaction()
+
+	var people []*Person
+	op.Select("people", &Person{}).Where("age > ?", 30).AllObjects(&people)
+	assert.True(op.Err() == nil, "Operation has no error", t)
+	assert.Equal(2, len(people), "Two people were returned", t)
+	assert.Equal("Sam", people[1].Name, "Rows were scanned into the structure", t)
+	mock.AssertExpectations(t)
+}
+
+func TestErrorsAndUnexpectedCalls(t *testing.T) {
+	var db, mock = magicsqltest.New()
+	var dupe = errors.New("duplicate")
+	mock.ExpectExec("INSERT INTO people (name,age) VALUES (?,?)").WillReturnError(dupe)
+	mock.ExpectQuery("SELECT 1")
+
+	var op = db.Operation()
+	op.Save("people", &Person{Name: "Pat"})
+	assert.True(errors.Is(op.Err(), dupe), "Mocked error is the Operation's error", t)
+
+	op = db.Operation()
+	op.Exec("DELETE FROM people")
+	assert.True(op.Err() != nil, "Unexpected exec fails", t)
+
+	var err = mock.ExpectationsWereMet()
+	assert.True(err != nil, "Expectations weren't met", t)
+	assert.True(strings.Contains(err.Error(), `expected but not run: query "SELECT 1"`), "Unmet expectation is reported", t)
+	assert.True(strings.Contains(err.Error(), `unexpected: exec "DELETE FROM people"`), "Unexpected call is reported", t)
+}
+
+func TestUnorderedAndPrepared(t *testing.T) {
+	var db, mock = magicsqltest.New()
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec("DELETE FROM b").WithArgs(2)
+	mock.ExpectExec("DELETE FROM a").WithArgs(1)
+	db.SetStatementCacheSize(10)
+
+	var op = db.Operation()
+	var st = op.Prepare("DELETE FROM a")
+	st.Exec(1)
+	st.Close()
+	op.Exec("DELETE   FROM\n\tb", 2)
+	assert.True(op.Err() == nil, "Unordered expectations matched", t)
+	mock.AssertExpectations(t)
+}
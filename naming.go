@@ -0,0 +1,199 @@
+package magicsql
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NamingStrategy derives database names from Go names: column names from
+// structure field names, for fields without a name in their tag, and table
+// names from structure type names, for tables created without a name
+type NamingStrategy interface {
+	ColumnName(field string) string
+	TableName(typeName string) string
+}
+
+// Built-in naming strategies.  Each derives table names by pluralizing the
+// type name after converting it the same way as a column name.
+var (
+	// SnakeCase is the default: "CreatedAt" becomes "created_at", and "FooBar"
+	// becomes "foo_bars"
+	SnakeCase NamingStrategy = snakeCase{}
+
+	// CamelCase lowercases the leading capital(s): "CreatedAt" becomes
+	// "createdAt", "ID" becomes "id", and "FooBar" becomes "fooBars".  Inlined
+	// prefixes are joined by capitalizing the column name, so "HomeAddress"
+	// and "street" become "homeAddressStreet".
+	CamelCase NamingStrategy = camelCase{}
+
+	// LowerCase lowercases everything: "CreatedAt" becomes "createdat", and
+	// "FooBar" becomes "foobars"
+	LowerCase NamingStrategy = lowerCase{}
+
+	// Identity leaves names alone: "CreatedAt" stays "CreatedAt", and "FooBar"
+	// becomes "FooBars".  Inlined prefixes are joined without a separator.
+	Identity NamingStrategy = identity{}
+)
+
+type snakeCase struct{}
+
+func (snakeCase) ColumnName(field string) string   { return toUnderscore(field) }
+func (snakeCase) TableName(typeName string) string { return pluralize(toUnderscore(typeName)) }
+
+type camelCase struct{}
+
+func (camelCase) ColumnName(field string) string   { return toCamel(field) }
+func (camelCase) TableName(typeName string) string { return pluralize(toCamel(typeName)) }
+func (camelCase) JoinPrefix(prefix, name string) string {
+	if name == "" {
+		return prefix
+	}
+	var r, size = utf8.DecodeRuneInString(name)
+	return prefix + string(unicode.ToUpper(r)) + name[size:]
+}
+
+type lowerCase struct{}
+
+func (lowerCase) ColumnName(field string) string   { return strings.ToLower(field) }
+func (lowerCase) TableName(typeName string) string { return pluralize(strings.ToLower(typeName)) }
+
+type identity struct{}
+
+func (identity) ColumnName(field string) string        { return field }
+func (identity) TableName(typeName string) string      { return pluralize(typeName) }
+func (identity) JoinPrefix(prefix, name string) string { return prefix + name }
+
+// PrefixJoiner may be implemented by a NamingStrategy to control how an
+// inlined structure's prefix is joined to the names of its columns.
+// Strategies which don't implement it join them with an underscore.
+type PrefixJoiner interface {
+	JoinPrefix(prefix, name string) string
+}
+
+// joinPrefix joins prefix to name using ns, or returns name if there's no
+// prefix
+func joinPrefix(ns NamingStrategy, prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if j, ok := ns.(PrefixJoiner); ok {
+		return j.JoinPrefix(prefix, name)
+	}
+	return prefix + "_" + name
+}
+
+// SetNamingStrategy sets how column names are derived from field names for
+// tables this DB's Operations create with Operation.Table, and how tables
+// created without a name are named.  nil means SnakeCase.
+func (db *DB) SetNamingStrategy(ns NamingStrategy) {
+	db.mu.Lock()
+	db.naming = ns
+	db.mu.Unlock()
+}
+
+// SetTagKeys sets the structure tag keys read for tables this DB's Operations
+// create with Operation.Table, in order of preference.  For instance,
+// SetTagKeys("sql", "db") reads sqlx-style `db:"..."` tags on fields which
+// have no "sql" tag.  With no keys, only "sql" is read.
+func (db *DB) SetTagKeys(keys ...string) {
+	db.mu.Lock()
+	db.tagKeys = append([]string(nil), keys...)
+	db.mu.Unlock()
+}
+
+// namingConfig returns the DB's naming strategy and tag keys
+func (db *DB) namingConfig() (NamingStrategy, []string) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.naming, db.tagKeys
+}
+
+// NamingFuncs adapts plain functions to the NamingStrategy and PrefixJoiner
+// interfaces.  Any function may be nil, in which case SnakeCase is used for
+// that name.  Since table metadata is cached per naming strategy, pass a
+// *NamingFuncs to DB.SetNamingStrategy so the strategy can be used as a cache
+// key, and reuse it rather than creating one per DB or Operation.
+type NamingFuncs struct {
+	Column func(field string) string
+	Table  func(typeName string) string
+	Join   func(prefix, name string) string
+}
+
+// ColumnName implements NamingStrategy
+func (nf NamingFuncs) ColumnName(field string) string {
+	if nf.Column == nil {
+		return SnakeCase.ColumnName(field)
+	}
+	return nf.Column(field)
+}
+
+// TableName implements NamingStrategy
+func (nf NamingFuncs) TableName(typeName string) string {
+	if nf.Table == nil {
+		return SnakeCase.TableName(typeName)
+	}
+	return nf.Table(typeName)
+}
+
+// JoinPrefix implements PrefixJoiner
+func (nf NamingFuncs) JoinPrefix(prefix, name string) string {
+	if nf.Join == nil {
+		return prefix + "_" + name
+	}
+	return nf.Join(prefix, name)
+}
+
+// toCamel lowercases the leading run of capitals in s, leaving the last one
+// alone if it starts a new word ("HTTPServer" becomes "httpServer")
+func toCamel(s string) string {
+	var runes = []rune(s)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// pluralize applies simple English pluralization rules to the last word of
+// name.  It doesn't know irregular plurals, so tables like "people" need to
+// be named explicitly.
+func pluralize(name string) string {
+	var lower = strings.ToLower(name)
+	switch {
+	case name == "":
+		return name
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + matchCase(name, "es")
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + matchCase(name, "ies")
+	}
+	return name + matchCase(name, "s")
+}
+
+// matchCase returns suffix in upper case if name ends with an upper case
+// letter, so "BOX" becomes "BOXES" rather than "BOXes"
+func matchCase(name, suffix string) string {
+	var r, _ = utf8.DecodeLastRuneInString(name)
+	if unicode.IsUpper(r) {
+		return strings.ToUpper(suffix)
+	}
+	return suffix
+}
+
+// lookupTag returns the value for the first of the given keys present in tag
+func lookupTag(tag reflect.StructTag, keys []string) string {
+	for _, key := range keys {
+		if v, ok := tag.Lookup(key); ok {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package magicsql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+type Category struct {
+	ID        int `sql:",primary"`
+	ShortName string
+	HTTPCode  int
+}
+
+type SqlxFoo struct {
+	ID      int    `db:"id" sql:",primary"`
+	Name    string `db:"full_name"`
+	Ignored string `db:"-"`
+	Plain   string
+}
+
+func TestNamingStrategies(t *testing.T) {
+	assert.Equal("created_at", SnakeCase.ColumnName("CreatedAt"), "SnakeCase column", t)
+	assert.Equal("createdAt", CamelCase.ColumnName("CreatedAt"), "CamelCase column", t)
+	assert.Equal("httpServer", CamelCase.ColumnName("HTTPServer"), "CamelCase keeps the start of the next word", t)
+	assert.Equal("id", CamelCase.ColumnName("ID"), "CamelCase all capitals", t)
+	assert.Equal("createdat", LowerCase.ColumnName("CreatedAt"), "LowerCase column", t)
+	assert.Equal("CreatedAt", Identity.ColumnName("CreatedAt"), "Identity column", t)
+
+	assert.Equal("foo_bars", SnakeCase.TableName("FooBar"), "SnakeCase table", t)
+	assert.Equal("categories", SnakeCase.TableName("Category"), "Consonant-y plural", t)
+	assert.Equal("days", SnakeCase.TableName("Day"), "Vowel-y plural", t)
+	assert.Equal("boxes", SnakeCase.TableName("Box"), "X plural", t)
+	assert.Equal("matches", SnakeCase.TableName("Match"), "Ch plural", t)
+	assert.Equal("fooBars", CamelCase.TableName("FooBar"), "CamelCase table", t)
+	assert.Equal("BOXES", Identity.TableName("BOX"), "Plural suffix matches case", t)
+
+	var nf = NamingFuncs{Column: strings.ToUpper}
+	assert.Equal("SHORTNAME", nf.ColumnName("ShortName"), "NamingFuncs column", t)
+	assert.Equal("foo_bars", nf.TableName("FooBar"), "NamingFuncs falls back to SnakeCase", t)
+}
+
+func TestDerivedTableName(t *testing.T) {
+	var mt = Table("", &Category{})
+	assert.Equal("categories", mt.Name, "Table name is derived from the type", t)
+	assert.Equal("id,short_name,http_code", strings.Join(mt.FieldNames(), ","), "Default column names", t)
+
+	mt = &MagicTable{Object: &Category{}, Naming: CamelCase}
+	mt.Configure(nil)
+	assert.Equal("categories", mt.Name, "CamelCase table name", t)
+	assert.Equal("id,shortName,httpCode", strings.Join(mt.FieldNames(), ","), "CamelCase column names", t)
+
+	mt = Table("cats", &Category{})
+	assert.Equal("cats", mt.Name, "An explicit name is kept", t)
+}
+
+func TestTagKeys(t *testing.T) {
+	var mt = &MagicTable{Object: &SqlxFoo{}}
+	mt.Configure(nil)
+	assert.Equal("id,name,ignored,plain", strings.Join(mt.FieldNames(), ","), "Only sql tags are read by default", t)
+
+	mt = &MagicTable{Object: &SqlxFoo{}, TagKeys: []string{"sql", "db"}}
+	mt.Configure(nil)
+	assert.Equal("id,full_name,plain", strings.Join(mt.FieldNames(), ","), "db tags are read for fields without sql tags", t)
+	assert.Equal("id", mt.primaryKey.Name, "sql tags take precedence", t)
+}
+
+func TestDBNaming(t *testing.T) {
+	var db, err = Open("sqlite3", "./test.db")
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.DataSource().Exec(`
+		drop table if exists categories;
+		create table categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			shortName text,
+			httpCode int
+		);
+	`)
+	if err != nil {
+		panic(err)
+	}
+
+	db.SetNamingStrategy(CamelCase)
+	var op = db.Operation()
+	op.Save("", &Category{ShortName: "misc", HTTPCode: 404})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var cat = &Category{}
+	op.Select("", cat).First(cat)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("misc", cat.ShortName, "CamelCase column round trip", t)
+	assert.Equal(404, cat.HTTPCode, "CamelCase acronym column round trip", t)
+
+	// Strategies which can't be cache keys still work
+	db.SetNamingStrategy(NamingFuncs{Column: toCamel})
+	db.SetTagKeys("sql", "db")
+	op = db.Operation()
+	cat = &Category{}
+	op.Select("categories", cat).First(cat)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal("misc", cat.ShortName, "NamingFuncs column round trip", t)
+}
+
+// wrappedNaming is a comparable type holding a strategy which may not be
+// comparable itself
+type wrappedNaming struct {
+	NamingStrategy
+}
+
+func TestUncomparableNaming(t *testing.T) {
+	var ns = wrappedNaming{NamingFuncs{Column: strings.ToUpper}}
+	var mt = cachedTable("", reflect.TypeOf(Category{}), nil, ns, nil)
+	assert.Equal("SHORTNAME", mt.FieldNames()[1], "Strategy holding functions is used without caching", t)
+	assert.True(mt != cachedTable("", reflect.TypeOf(Category{}), nil, ns, nil), "Table isn't cached", t)
+}
+
+func TestInlinePrefixNaming(t *testing.T) {
+	var mt = &MagicTable{Object: &InlineFoo{}, Naming: CamelCase}
+	mt.Configure(nil)
+	assert.Equal("addressStreet,addressCity,addressGeoLat,addressGeoLng", strings.Join(mt.FieldNames()[1:5], ","),
+		"CamelCase joins nested prefixes", t)
+	assert.Equal("billAmount,billCur", strings.Join(mt.FieldNames()[5:7], ","), "Tagged prefix is joined", t)
+
+	mt = &MagicTable{Object: &InlineFoo{}, Naming: &NamingFuncs{Join: func(prefix, name string) string { return prefix + "__" + name }}}
+	mt.Configure(nil)
+	assert.Equal("bill__amount", mt.FieldNames()[5], "NamingFuncs joins prefixes", t)
+}
+
+func TestReconfigureNaming(t *testing.T) {
+	var mt = &MagicTable{Object: &Category{}}
+	mt.Configure(nil)
+	assert.Equal("categories", mt.Name, "SnakeCase table name", t)
+
+	mt.Naming = NamingFuncs{Table: strings.ToLower}
+	mt.Configure(nil)
+	assert.Equal("category", mt.Name, "Derived name follows a new strategy", t)
+
+	mt.Name = "cats"
+	mt.Naming = nil
+	mt.Configure(nil)
+	assert.Equal("cats", mt.Name, "A name set by the caller is kept", t)
+}
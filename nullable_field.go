@@ -1,8 +1,11 @@
 package magicsql
 
 import (
+	"bytes"
 	"database/sql"
-	"time"
+	"fmt"
+	"reflect"
+	"strconv"
 )
 
 // NullableField implements the sql Scanner interface to make null values suck
@@ -10,124 +13,217 @@ import (
 // the actual source can be set to a value that represents null or left at its
 // default.  Data loss can happen if the source fields aren't of proper size,
 // and not all types are supported.
+//
+// If Value points to a pointer (e.g., a *int64 field's address), NULL sets
+// the pointer to nil, and anything else allocates a new value for it.  If
+// Value implements sql.Scanner, scanning (including of NULLs) is left
+// entirely to it.
+//
+// When Strict is true, anything which would lose data returns a
+// *ConversionError instead: NULL into a non-pointer field, integers which
+// overflow the field or are negative for an unsigned field, and values which
+// can't be parsed as the field's type.  Column and Field are only used to
+// describe the problem in those errors.
 type NullableField struct {
-	Value interface{}
+	Value  interface{}
+	Strict bool
+	Column string
+	Field  string
+
+	// converters are the DB's Converters, checked before the package-wide ones
+	converters map[reflect.Type]Converter
+
+	// timeFormat controls reading time.Time fields; nil means the defaults
+	timeFormat *timeFormat
 }
 
-// Scan implements the Scanner interface.  Always returns a nil error.  Only
-// works with primitive types or simple mappings of time.Time fields.
+// Scan implements the Scanner interface.  Works with fields of any type
+// which has a registered Converter, sql.Scanner implementations, time.Time,
+// types whose underlying type is a number, bool, string or []byte (such as
+// json.RawMessage), and pointers to any of those.  Returns an error for any
+// other type rather than silently leaving the value alone.
 func (nf *NullableField) Scan(src interface{}) error {
-	// Create a nullable field based on the type of the destination data
-	switch nf.Value.(type) {
-	case *int, *int8, *int16, *int32, *int64, *uint, *uint8, *uint16, *uint32, *uint64:
-		nf.storeInt(src)
-	case *float32, *float64:
-		nf.storeFloat(src)
-	case *bool:
-		nf.storeBool(src)
-	case *string:
-		nf.storeString(src)
-	case *time.Time:
-		nf.storeTime(src)
+	var rv = reflect.ValueOf(nf.Value)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("magicsql: NullableField.Value must be a non-nil pointer, not %T", nf.Value)
 	}
+	var dest = rv.Elem()
 
-	return nil
+	if c, ok := lookupConverter(nf.converters, dest.Type()); ok && c.FromDB != nil {
+		return c.FromDB(src, nf.Value)
+	}
+	if s, ok := nf.Value.(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	if dest.Kind() == reflect.Ptr {
+		return nf.scanPointer(dest, src)
+	}
+	if dest.Type() == timeType {
+		return nf.storeTime(dest, src)
+	}
+	if dest.Kind() == reflect.Slice && dest.Type().Elem().Kind() == reflect.Uint8 {
+		return nf.storeBytes(dest, src)
+	}
+	if store, ok := kindStores[dest.Kind()]; ok {
+		return store(nf, dest, src)
+	}
+
+	return fmt.Errorf("magicsql: unsupported destination type %T (implement sql.Scanner or register a Converter to read it)", nf.Value)
+}
+
+// kindStores hold the conversion for each kind of value NullableField
+// handles without any help
+var kindStores = map[reflect.Kind]func(nf *NullableField, d reflect.Value, src interface{}) error{
+	reflect.Int:     (*NullableField).storeInt,
+	reflect.Int8:    (*NullableField).storeInt,
+	reflect.Int16:   (*NullableField).storeInt,
+	reflect.Int32:   (*NullableField).storeInt,
+	reflect.Int64:   (*NullableField).storeInt,
+	reflect.Uint:    (*NullableField).storeUint,
+	reflect.Uint8:   (*NullableField).storeUint,
+	reflect.Uint16:  (*NullableField).storeUint,
+	reflect.Uint32:  (*NullableField).storeUint,
+	reflect.Uint64:  (*NullableField).storeUint,
+	reflect.Float32: (*NullableField).storeFloat,
+	reflect.Float64: (*NullableField).storeFloat,
+	reflect.Bool:    (*NullableField).storeBool,
+	reflect.String:  (*NullableField).storeString,
 }
 
-func (nf *NullableField) storeInt(src interface{}) {
+// scanPointer sets p to nil for a NULL src, or to a newly allocated value
+// scanned from src
+func (nf *NullableField) scanPointer(p reflect.Value, src interface{}) error {
+	if src == nil {
+		p.Set(reflect.Zero(p.Type()))
+		return nil
+	}
+
+	var v = reflect.New(p.Type().Elem())
+	var inner = *nf
+	inner.Value = v.Interface()
+	var err = inner.Scan(src)
+	if err == nil {
+		p.Set(v)
+	}
+	return err
+}
+
+// fail returns a ConversionError in strict mode, or nil otherwise
+func (nf *NullableField) fail(src interface{}, reason error) error {
+	if !nf.Strict {
+		return nil
+	}
+	return &ConversionError{Column: nf.Column, Field: nf.Field, Value: src, Err: reason}
+}
+
+func (nf *NullableField) storeInt(d reflect.Value, src interface{}) error {
 	var n sql.NullInt64
-	n.Scan(src)
+	if err := n.Scan(src); err != nil {
+		if _, ok := src.(uint64); ok {
+			return nf.fail(src, ErrOverflow)
+		}
+		return nf.fail(src, ErrInvalidValue)
+	}
 	if !n.Valid {
-		return
-	}
-	var i = n.Int64
-
-	switch d := nf.Value.(type) {
-	case *int:
-		*d = int(i)
-	case *int8:
-		*d = int8(i)
-	case *int16:
-		*d = int16(i)
-	case *int32:
-		*d = int32(i)
-	case *int64:
-		*d = int64(i)
-	case *uint:
-		*d = uint(i)
-	case *uint8:
-		*d = uint8(i)
-	case *uint16:
-		*d = uint16(i)
-	case *uint32:
-		*d = uint32(i)
-	case *uint64:
-		*d = uint64(i)
+		return nf.fail(src, ErrUnexpectedNull)
 	}
+
+	if nf.Strict && d.OverflowInt(n.Int64) {
+		return nf.fail(src, ErrOverflow)
+	}
+	d.SetInt(n.Int64)
+	return nil
 }
 
-func (nf *NullableField) storeFloat(src interface{}) {
+func (nf *NullableField) storeUint(d reflect.Value, src interface{}) error {
+	var u uint64
+	switch v := src.(type) {
+	case nil:
+		return nf.fail(src, ErrUnexpectedNull)
+	case uint64:
+		u = v
+	default:
+		var n sql.NullInt64
+		if err := n.Scan(src); err != nil {
+			// Values past the range of int64 may still fit
+			var s sql.NullString
+			s.Scan(src)
+			var perr error
+			u, perr = strconv.ParseUint(s.String, 10, 64)
+			if perr != nil {
+				return nf.fail(src, ErrInvalidValue)
+			}
+			break
+		}
+		if n.Int64 < 0 && nf.Strict {
+			return nf.fail(src, ErrSignLoss)
+		}
+		u = uint64(n.Int64)
+	}
+
+	if nf.Strict && d.OverflowUint(u) {
+		return nf.fail(src, ErrOverflow)
+	}
+	d.SetUint(u)
+	return nil
+}
+
+func (nf *NullableField) storeFloat(d reflect.Value, src interface{}) error {
 	var n sql.NullFloat64
-	n.Scan(src)
+	if err := n.Scan(src); err != nil {
+		return nf.fail(src, ErrInvalidValue)
+	}
 	if !n.Valid {
-		return
+		return nf.fail(src, ErrUnexpectedNull)
 	}
-	var f = n.Float64
 
-	switch d := nf.Value.(type) {
-	case *float32:
-		*d = float32(f)
-	case *float64:
-		*d = float64(f)
+	if nf.Strict && d.OverflowFloat(n.Float64) {
+		return nf.fail(src, ErrOverflow)
 	}
+	d.SetFloat(n.Float64)
+	return nil
 }
 
-func (nf *NullableField) storeBool(src interface{}) {
+func (nf *NullableField) storeBool(d reflect.Value, src interface{}) error {
 	var n sql.NullBool
-	n.Scan(src)
+	if err := n.Scan(src); err != nil {
+		return nf.fail(src, ErrInvalidValue)
+	}
 	if !n.Valid {
-		return
+		return nf.fail(src, ErrUnexpectedNull)
 	}
-	d := nf.Value.(*bool)
-	*d = n.Bool
+	d.SetBool(n.Bool)
+	return nil
 }
 
-func (nf *NullableField) storeString(src interface{}) {
+func (nf *NullableField) storeString(d reflect.Value, src interface{}) error {
 	var n sql.NullString
-	n.Scan(src)
+	if err := n.Scan(src); err != nil {
+		return nf.fail(src, ErrInvalidValue)
+	}
 	if !n.Valid {
-		return
+		return nf.fail(src, ErrUnexpectedNull)
 	}
-	d := nf.Value.(*string)
-	*d = n.String
+	d.SetString(n.String)
+	return nil
 }
 
-func (nf *NullableField) storeTime(src interface{}) {
-	d := nf.Value.(*time.Time)
-	switch st := src.(type) {
-	case time.Time:
-		*d = st
-	case string:
-		*d = parseTime(st).Local()
+// storeBytes copies src into a byte slice field, since the driver may reuse
+// its buffer once the next row is read.  NULL sets the slice to nil.
+func (nf *NullableField) storeBytes(d reflect.Value, src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		d.Set(reflect.Zero(d.Type()))
 	case []byte:
-		*d = parseTime(string(st)).Local()
-	}
-}
-
-// parseTime attempts to parse a string into a time, using formats I've seen in
-// mysql and sqlite
-func parseTime(s string) time.Time {
-	var fmts = []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02 15:04:05-07:00",
-	}
-	var t time.Time
-	var err error
-	for _, fmt := range fmts {
-		t, err = time.Parse(fmt, s)
-		if err == nil {
-			return t
+		d.SetBytes(bytes.Clone(v))
+	case string:
+		d.SetBytes([]byte(v))
+	default:
+		var n sql.NullString
+		if err := n.Scan(src); err != nil {
+			return nf.fail(src, ErrInvalidValue)
 		}
+		d.SetBytes([]byte(n.String))
 	}
-	return t
+	return nil
 }
@@ -0,0 +1,257 @@
+package magicsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+type NullFoo struct {
+	ID      int `sql:",primary"`
+	Count   *int64
+	Name    *string
+	Ratio   *float64
+	Active  *bool
+	SeenAt  *time.Time
+	Comment string
+}
+
+func getdbNull() *DB {
+	var db, err = Open("sqlite3", "./test.db")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.DataSource().Exec(`
+		drop table if exists null_foos;
+		create table null_foos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			count int,
+			name text,
+			ratio real,
+			active bool,
+			seen_at datetime,
+			comment text
+		);
+	`)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+func TestPointerFields(t *testing.T) {
+	var db = getdbNull()
+	var op = db.Operation()
+
+	var count, name, ratio, active = int64(0), "", 0.5, false
+	var seen = time.Unix(1473000000, 0)
+	op.Save("null_foos", &NullFoo{Count: &count, Name: &name, Ratio: &ratio, Active: &active, SeenAt: &seen})
+	op.Save("null_foos", &NullFoo{Comment: "all null"})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var n int
+	db.DataSource().QueryRow("SELECT COUNT(*) FROM null_foos WHERE count IS NULL AND name IS NULL").Scan(&n)
+	assert.Equal(1, n, "Nil pointers are stored as NULL", t)
+
+	var foos []*NullFoo
+	op.Select("null_foos", &NullFoo{}).Order("id").AllObjects(&foos)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(2, len(foos), "Read both records", t)
+
+	var f = foos[0]
+	assert.True(f.Count != nil && *f.Count == 0, "Zero is not NULL", t)
+	assert.True(f.Name != nil && *f.Name == "", "Empty string is not NULL", t)
+	assert.True(f.Ratio != nil && *f.Ratio == 0.5, "Float pointer is read", t)
+	assert.True(f.Active != nil && !*f.Active, "False is not NULL", t)
+	assert.True(f.SeenAt != nil && f.SeenAt.Unix() == seen.Unix(), "Time pointer is read", t)
+
+	f = foos[1]
+	assert.True(f.Count == nil && f.Name == nil && f.Ratio == nil && f.Active == nil && f.SeenAt == nil,
+		"NULLs are read as nil pointers", t)
+	assert.Equal("all null", f.Comment, "Non-pointer field is read", t)
+
+	// A non-nil pointer is cleared when the column is NULL
+	f.Count = &count
+	op.Select("null_foos", &NullFoo{}).Where("id = ?", 2).First(f)
+	assert.True(f.Count == nil, "Existing pointer is set to nil for NULL", t)
+}
+
+// CSV is a custom type stored as a comma-separated string
+type CSV []string
+
+func (c *CSV) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+	case string:
+		*c = strings.Split(v, ",")
+	case []byte:
+		*c = strings.Split(string(v), ",")
+	default:
+		return fmt.Errorf("can't scan %T into CSV", src)
+	}
+	return nil
+}
+
+func (c CSV) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return strings.Join(c, ","), nil
+}
+
+type ScannerFoo struct {
+	ID      int `sql:",primary"`
+	Name    sql.NullString
+	Comment CSV
+	Count   *sql.NullInt64
+}
+
+func TestScannerValuerFields(t *testing.T) {
+	var db = getdbNull()
+	db.DataSource().Exec("ALTER TABLE null_foos RENAME TO scanner_foos")
+	var op = db.Operation()
+
+	var foo = &ScannerFoo{Name: sql.NullString{String: "foo", Valid: true}, Comment: CSV{"a", "b"}}
+	var args = Table("scanner_foos", foo).InsertArgs(foo)
+	var _, isCSV = args[1].(CSV)
+	assert.True(isCSV, "Valuers are passed to the driver directly", t)
+
+	op.Save("scanner_foos", foo)
+	op.Save("scanner_foos", &ScannerFoo{Count: &sql.NullInt64{Int64: 0, Valid: true}})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var foos []*ScannerFoo
+	op.Select("scanner_foos", &ScannerFoo{}).Order("id").AllObjects(&foos)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(sql.NullString{String: "foo", Valid: true}, foos[0].Name, "sql.NullString is scanned", t)
+	assert.Equal("a|b", strings.Join(foos[0].Comment, "|"), "Custom Scanner is used", t)
+	assert.True(foos[0].Count == nil, "NULL into a pointer to a Scanner is nil", t)
+	assert.False(foos[1].Name.Valid, "NULL is left to the Scanner", t)
+	assert.True(foos[1].Count != nil && foos[1].Count.Valid, "Pointer to a Scanner is allocated and scanned", t)
+}
+
+type UnsupportedFoo struct {
+	ID   int `sql:",primary"`
+	Name map[string]string
+}
+
+func TestUnsupportedField(t *testing.T) {
+	var db = getdbNull()
+	db.DataSource().Exec("INSERT INTO null_foos (name) VALUES ('foo')")
+	var op = db.Operation()
+
+	var foo = &UnsupportedFoo{}
+	op.Select("null_foos", foo).First(foo)
+
+	var oe *OpError
+	assert.True(errors.As(op.Err(), &oe), "Unsupported field type is an error", t)
+	assert.Equal(PhaseScan, oe.Phase, "The error happened while scanning", t)
+	assert.True(strings.Contains(op.Err().Error(), "unsupported destination type *map[string]string"),
+		fmt.Sprintf("Error (%s) names the type", op.Err()), t)
+}
+
+func TestNonStrictConversion(t *testing.T) {
+	var i8 int8 = 7
+	var nf = &NullableField{Value: &i8}
+	assert.True(nf.Scan(int64(300)) == nil, "Overflow isn't an error by default", t)
+	assert.Equal(int8(44), i8, "Overflowing value is truncated", t)
+	assert.True(nf.Scan(nil) == nil, "NULL isn't an error by default", t)
+	assert.Equal(int8(44), i8, "NULL leaves the value alone", t)
+	assert.True(nf.Scan("abc") == nil, "Unparseable value isn't an error by default", t)
+}
+
+func TestStrictConversion(t *testing.T) {
+	var i8 int8
+	var u8 uint8
+	var u64 uint64
+	var f32 float32
+	var b bool
+	var s string
+	var tm time.Time
+
+	var tests = []struct {
+		dest   interface{}
+		src    interface{}
+		reason error
+	}{
+		{&i8, int64(300), ErrOverflow},
+		{&i8, int64(-129), ErrOverflow},
+		{&i8, "12x", ErrInvalidValue},
+		{&i8, nil, ErrUnexpectedNull},
+		{&u8, int64(-1), ErrSignLoss},
+		{&u8, int64(256), ErrOverflow},
+		{&u8, []byte("abc"), ErrInvalidValue},
+		{&f32, float64(1e39), ErrOverflow},
+		{&f32, "pi", ErrInvalidValue},
+		{&b, "maybe", ErrInvalidValue},
+		{&s, nil, ErrUnexpectedNull},
+		{&tm, "yesterday", ErrInvalidValue},
+		{&tm, int64(5), ErrInvalidValue},
+		{&tm, nil, ErrUnexpectedNull},
+	}
+
+	for _, test := range tests {
+		var nf = &NullableField{Value: test.dest, Strict: true, Column: "col", Field: "Foo.Bar"}
+		var err = nf.Scan(test.src)
+		var desc = fmt.Sprintf("Scanning %#v into %T", test.src, test.dest)
+		assert.True(errors.Is(err, test.reason), fmt.Sprintf("%s is %q (got %v)", desc, test.reason, err), t)
+
+		var ce *ConversionError
+		assert.True(errors.As(err, &ce) && ce.Column == "col" && ce.Field == "Foo.Bar",
+			desc+" names the column and field", t)
+	}
+
+	var nf = &NullableField{Value: &u64, Strict: true}
+	assert.True(nf.Scan([]byte("18446744073709551615")) == nil, "Max uint64 from text fits", t)
+	assert.Equal(uint64(18446744073709551615), u64, "Max uint64 is stored", t)
+
+	nf = &NullableField{Value: &u8, Strict: true}
+	assert.True(nf.Scan(int64(255)) == nil, "Max uint8 fits", t)
+	assert.Equal(uint8(255), u8, "Max uint8 is stored", t)
+
+	var p *int8
+	nf = &NullableField{Value: &p, Strict: true}
+	assert.True(nf.Scan(nil) == nil, "NULL into a pointer is fine in strict mode", t)
+	assert.True(errors.Is(nf.Scan(int64(1000)), ErrOverflow), "Strictness applies through pointers", t)
+}
+
+type SmallFoo struct {
+	ID    int `sql:",primary"`
+	Count int8
+	Name  string
+}
+
+func TestStrictDB(t *testing.T) {
+	var db = getdbNull()
+	db.DataSource().Exec("INSERT INTO null_foos (count, name) VALUES (1000, 'foo')")
+
+	var op = db.Operation()
+	var foo = &SmallFoo{}
+	assert.True(op.Select("null_foos", foo).First(foo), "Non-strict scan", t)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	db.SetStrict(true)
+	op = db.Operation()
+	op.Select("null_foos", foo).First(foo)
+	var ce *ConversionError
+	assert.True(errors.As(op.Err(), &ce), fmt.Sprintf("Strict scan error (%s) is a ConversionError", op.Err()), t)
+	assert.Equal("count", ce.Column, "Error names the column", t)
+	assert.Equal("SmallFoo.Count", ce.Field, "Error names the field", t)
+	assert.True(errors.Is(op.Err(), ErrOverflow), "Error is an overflow", t)
+
+	db.SetStrict(false)
+	var table = Table("null_foos", &SmallFoo{})
+	table.Strict = true
+	op = db.Operation()
+	op.OperationTable(table).Select().First(foo)
+	assert.True(errors.Is(op.Err(), ErrOverflow), "MagicTable.Strict turns on strict mode", t)
+}
@@ -1,20 +1,25 @@
 package magicsql
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"reflect"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 // Querier defines an interface for top-level sql types that can run SQL and
 // prepare statements
 type Querier interface {
-	Query(string, ...interface{}) (*sql.Rows, error)
-	Exec(string, ...interface{}) (sql.Result, error)
-	Prepare(string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
 }
 
 // Operation represents a short-lived single-purpose combination of database
@@ -25,32 +30,113 @@ type Querier interface {
 // when it makes sense.
 //
 // When a transaction is started, the operation will route all database calls
-// through the transaction instead of the global database handler.  At this
-// time, only one transaction at a time is supported (i.e., no nesting
-// transactions).
+// through the transaction instead of the global database handler.  Starting a
+// transaction while one is already in progress creates a SAVEPOINT instead, so
+// transactional functions can be composed.
+//
+// Every database call is made with the Operation's context, so cancelling the
+// context (or hitting its deadline) stops in-flight work and leaves the
+// context's error, wrapped in an OpError, as the Operation's error.
 type Operation struct {
 	parent *DB
+	ctx    context.Context
 	err    error
 	tx     *sql.Tx
 	q      Querier
 	Dbg    bool
+
+	// primary forces reads to the primary database even if there are replicas
+	primary bool
+
+	// txOpts is used for every top-level transaction started by
+	// BeginTransaction
+	txOpts *sql.TxOptions
+
+	// txStmts holds the cached statements rebound to the current transaction
+	txStmts map[string]*sql.Stmt
+
+	// hooks are run around every database call
+	hooks []Hook
+
+	// notFoundIsError makes Select.First store a NotFoundError when there are
+	// no rows
+	notFoundIsError bool
+
+	// redactArgs hides arguments in OpErrors
+	redactArgs bool
+
+	// logger, if set, writes a log record after every database call
+	logger *QueryLogger
+
+	// savepoints holds the open nested transactions, innermost last
+	savepoints []savepoint
+}
+
+// savepoint is one level of nested transaction.  created is false if the
+// Operation already had an error when the level was opened, in which case no
+// SAVEPOINT was ever sent and there's nothing to roll back to or release.
+type savepoint struct {
+	name    string
+	created bool
 }
 
 // NewOperation creates an operation in its default state: its parent is the
 // passed-in DB instance, and it defaults to using direct database calls until
 // a transaction is started.
 func NewOperation(db *DB) *Operation {
-	var o = &Operation{parent: db}
+	return NewOperationContext(context.Background(), db)
+}
+
+// NewOperationContext works like NewOperation, but ties all database calls to
+// the given context
+func NewOperationContext(ctx context.Context, db *DB) *Operation {
+	db.mu.RLock()
+	var o = &Operation{
+		parent:     db,
+		ctx:        ctx,
+		txOpts:     db.txOpts,
+		hooks:      db.hooks,
+		logger:     db.logger,
+		redactArgs: db.redactArgs,
+	}
+	db.mu.RUnlock()
 	o.q = o.parent.db
 
 	return o
 }
 
+// Context returns the context used for all of this Operation's database calls
+func (op *Operation) Context() context.Context {
+	if op.ctx == nil {
+		return context.Background()
+	}
+	return op.ctx
+}
+
 // Err returns the *first* error which occurred on any database call owned by the Operation
 func (op *Operation) Err() error {
 	return op.err
 }
 
+// dialect returns the parent DB's dialect, or Generic if there's no parent
+func (op *Operation) dialect() Dialect {
+	if op.parent == nil {
+		return Generic
+	}
+	return op.parent.Dialect()
+}
+
+// checkContext stores the context's error, if any, so a cancelled or expired
+// context halts the Operation before another call is even attempted.  The
+// error is wrapped in an OpError for the call which would have been made, so
+// an expired deadline is classified as ErrTimeout.
+func (op *Operation) checkContext(phase Phase, query string, args []interface{}) error {
+	if op.err == nil {
+		op.setErr(phase, query, args, op.Context().Err())
+	}
+	return op.err
+}
+
 // SetErr tells the Operation to stop handling any more queries.  It shouldn't
 // usually be called directly, but it can be if you need to tell the object
 // "here's a thing that may be an error; don't do any more work if it is".
@@ -62,34 +148,97 @@ func (op *Operation) SetErr(err error) {
 	op.err = err
 }
 
-// Query wraps sql's Query, returning a wrapped Rows object
+// Query wraps sql's Query, returning a wrapped Rows object.  Outside a
+// transaction, the query is sent to a read replica if the DB has any.
 func (op *Operation) Query(query string, args ...interface{}) *Rows {
+	return op.query(query, args, false)
+}
+
+// Exec wraps sql's DB.Exec, returning a wrapped Result
+func (op *Operation) Exec(query string, args ...interface{}) *Result {
+	return op.exec(query, args, false)
+}
+
+// query runs Query, using the DB's statement cache if cache is true
+func (op *Operation) query(query string, args []interface{}, cache bool) *Rows {
+	return op.queryOn(op.reader(), query, args, cache)
+}
+
+// queryOn is query, but runs against q rather than choosing a reader, for
+// queries which write data and so mustn't go to a replica
+func (op *Operation) queryOn(q Querier, query string, args []interface{}, cache bool) *Rows {
+	if op.checkContext(PhaseQuery, query, args) != nil {
+		return &Rows{op: op}
+	}
+
+	var e = op.beforeQuery(KindQuery, query, args)
 	if op.Err() != nil {
-		return &Rows{nil, op}
+		return &Rows{op: op}
 	}
 
 	if op.Dbg {
-		log.Printf("DEBUG - Querying: %s, %#v", query, stringifyArgs(args))
+		log.Printf("DEBUG - Querying: %s, %#v", e.SQL, stringifyArgs(e.Args))
 	}
 
-	var r, err = op.q.Query(query, args...)
-	op.SetErr(err)
-	return &Rows{r, op}
+	var st *sql.Stmt
+	var r *sql.Rows
+	var err error
+	var phase = PhaseQuery
+	var release = noRelease
+	// SQL rewritten by a hook isn't worth caching
+	if cache && e.SQL == query {
+		st, release, err = op.cachedStmt(q, e.SQL)
+	}
+	switch {
+	case err != nil:
+		phase = PhasePrepare
+	case st != nil:
+		r, err = st.QueryContext(op.Context(), e.Args...)
+		release()
+	default:
+		r, err = q.QueryContext(op.Context(), e.SQL, e.Args...)
+	}
+	op.afterQuery(e, nil, err)
+	op.setErr(phase, e.SQL, e.Args, err)
+	return &Rows{r, op, e.SQL, e.Args}
 }
 
-// Exec wraps sql's DB.Exec, returning a wrapped Result
-func (op *Operation) Exec(query string, args ...interface{}) *Result {
+// exec runs Exec, using the DB's statement cache if cache is true
+func (op *Operation) exec(query string, args []interface{}, cache bool) *Result {
+	if op.checkContext(PhaseExec, query, args) != nil {
+		return &Result{op: op}
+	}
+
+	var e = op.beforeQuery(KindExec, query, args)
 	if op.Err() != nil {
-		return &Result{nil, op}
+		return &Result{op: op}
 	}
 
 	if op.Dbg {
-		log.Printf("DEBUG - Executing: %s, %#v", query, stringifyArgs(args))
+		log.Printf("DEBUG - Executing: %s, %#v", e.SQL, stringifyArgs(e.Args))
 	}
 
-	var r, err = op.q.Exec(query, args...)
-	op.SetErr(err)
-	return &Result{r, op}
+	var st *sql.Stmt
+	var r sql.Result
+	var err error
+	var phase = PhaseExec
+	var release = noRelease
+	// SQL rewritten by a hook isn't worth caching
+	if cache && e.SQL == query {
+		st, release, err = op.cachedStmt(op.q, e.SQL)
+	}
+	switch {
+	case err != nil:
+		phase = PhasePrepare
+	case st != nil:
+		r, err = st.ExecContext(op.Context(), e.Args...)
+		release()
+	default:
+		r, err = op.q.ExecContext(op.Context(), e.SQL, e.Args...)
+	}
+	op.afterQuery(e, r, err)
+	op.setErr(phase, e.SQL, e.Args, err)
+	return &Result{r, op, e.SQL, e.Args}
 }
 
 func stringifyArgs(args ...interface{}) []string {
@@ -105,58 +254,97 @@ func stringifyArgs(args ...interface{}) []string {
 	return sArgs
 }
 
+// stringify turns an argument into a human-readable string for logging.
+// Pointers are followed, nil becomes NULL, and driver.Valuer types are
+// rendered by their database value.  Blobs are the exception: their Reader
+// can only be read once, so they're shown as "<blob>".
 func stringify(arg interface{}) string {
+	if arg == nil {
+		return "NULL"
+	}
+
+	var rv = reflect.ValueOf(arg)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return "NULL"
+	}
+	switch b := arg.(type) {
+	case Blob:
+		if b.Reader == nil {
+			return "NULL"
+		}
+		return "<blob>"
+	case *Blob:
+		return stringify(*b)
+	}
+	if v, ok := arg.(driver.Valuer); ok {
+		var dv, err = v.Value()
+		if err != nil {
+			return fmt.Sprintf("<<%s>>", err)
+		}
+		return stringify(dv)
+	}
+	if rv.Kind() == reflect.Ptr {
+		return stringify(rv.Elem().Interface())
+	}
+
 	switch v := arg.(type) {
-	case *int:
-		return strconv.FormatInt(int64(*v), 10)
-	case *int8:
-		return strconv.FormatInt(int64(*v), 10)
-	case *int16:
-		return strconv.FormatInt(int64(*v), 10)
-	case *int32:
-		return strconv.FormatInt(int64(*v), 10)
-	case *int64:
-		return strconv.FormatInt(*v, 10)
-	case *uint:
-		return strconv.FormatUint(uint64(*v), 10)
-	case *uint8:
-		return strconv.FormatUint(uint64(*v), 10)
-	case *uint16:
-		return strconv.FormatUint(uint64(*v), 10)
-	case *uint32:
-		return strconv.FormatUint(uint64(*v), 10)
-	case *uint64:
-		return strconv.FormatUint(*v, 10)
-	case *float32:
-		return strconv.FormatFloat(float64(*v), 'g', -1, 32)
-	case *float64:
-		return strconv.FormatFloat(*v, 'g', -1, 64)
-	case *bool:
-		return strconv.FormatBool(*v)
-	case *string:
-		return *v
-	case *time.Time:
-		return (*v).Format(time.RFC3339Nano)
-	default:
-		return fmt.Sprintf("<<%s>>", v)
+	case string:
+		return v
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return "0x" + hex.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.String:
+		return rv.String()
 	}
+
+	return fmt.Sprintf("%v", arg)
 }
 
 // Prepare wrap's sql's DB.Prepare, returning a wrapped Stmt.  The statement
 // must be closed by the caller or eventually MySQL will run out of prepared
 // statements.
 func (op *Operation) Prepare(query string) *Stmt {
+	if op.checkContext(PhasePrepare, query, nil) != nil {
+		return &Stmt{nil, query, op}
+	}
+
+	var e = op.beforeQuery(KindPrepare, query, nil)
 	if op.Err() != nil {
-		return &Stmt{nil, op}
+		return &Stmt{nil, query, op}
 	}
 
 	if op.Dbg {
-		log.Printf("DEBUG - Preparing: %s", query)
+		log.Printf("DEBUG - Preparing: %s", e.SQL)
 	}
 
-	var st, err = op.q.Prepare(query)
-	op.SetErr(err)
-	return &Stmt{st, op}
+	var st, err = op.q.PrepareContext(op.Context(), e.SQL)
+	op.afterQuery(e, nil, err)
+	op.setErr(PhasePrepare, e.SQL, nil, err)
+	return &Stmt{st, e.SQL, op}
+}
+
+// SetNotFoundIsError controls whether Select.First and Find store a
+// NotFoundError on the Operation when no row matches.  By default they simply
+// return false.
+func (op *Operation) SetNotFoundIsError(b bool) {
+	op.notFoundIsError = b
 }
 
 // Reset clears the error if any is present
@@ -164,31 +352,109 @@ func (op *Operation) Reset() {
 	op.err = nil
 }
 
-// BeginTransaction wraps sql's Begin and uses a wrapped sql.Tx to dispatch
+// BeginTransaction wraps sql's BeginTx and uses a wrapped sql.Tx to dispatch
 // Query, Exec, and Prepare calls.  When the transaction is complete, instead
 // of manually rolling back or committing, simply call op.EndTransaction() and
 // it will rollback / commit based on the error state.  If you need to force a
 // rollback, set an error manually with Operation.SetErr().
 //
-// If a transaction is started while one is already in progress, the operation
-// gets into an error state (i.e., nested transactions are not supported).
+// If a transaction is started while one is already in progress, a SAVEPOINT
+// is created instead.  Each BeginTransaction must be paired with its own
+// EndTransaction; the innermost EndTransaction releases the savepoint or, if
+// there's an error, rolls back to it.  The error is still the Operation's
+// error after an inner rollback, so the outer transaction will also roll back
+// unless the caller inspects the error and calls Reset.
+//
+// Top-level transactions use the Operation's default transaction options,
+// which it inherits from its DB.
 func (op *Operation) BeginTransaction() {
-	if op.Err() != nil {
-		return
+	op.begin(op.txOpts)
+}
+
+// BeginTransactionWith works like BeginTransaction, but uses the given options
+// (isolation level and read-only flag) instead of the Operation's defaults.
+// Options can't be applied to a savepoint, so if a transaction is already in
+// progress and opts is non-nil, the Operation gets into an error state.
+func (op *Operation) BeginTransactionWith(opts *sql.TxOptions) {
+	if op.tx != nil && opts != nil {
+		op.SetErr(errors.New("cannot set transaction options on a nested transaction"))
 	}
+	op.begin(opts)
+}
+
+// SetTxOptions overrides the default options used by BeginTransaction for
+// this Operation
+func (op *Operation) SetTxOptions(opts *sql.TxOptions) {
+	op.txOpts = opts
+}
 
+func (op *Operation) begin(opts *sql.TxOptions) {
 	if op.tx != nil {
-		op.SetErr(errors.New("cannot nest transactions"))
+		op.beginSavepoint()
+		return
+	}
+
+	if op.checkContext(PhaseBegin, "", nil) != nil {
 		return
 	}
 
-	var tx, err = op.parent.db.Begin()
+	var tx, err = op.parent.db.BeginTx(op.Context(), opts)
 	op.tx = tx
-	op.SetErr(err)
+	op.setErr(PhaseBegin, "", nil, err)
 	op.q = tx
 }
 
-// Rollback tries to roll back the transaction even if there is no error
+// TransactionDepth returns the number of open transactions: 0 when no
+// transaction is in progress, 1 for a top-level transaction, and one more for
+// each nested transaction (savepoint)
+func (op *Operation) TransactionDepth() int {
+	if op.tx == nil {
+		return 0
+	}
+	return len(op.savepoints) + 1
+}
+
+// beginSavepoint creates a new savepoint, named by its depth.  The level is
+// tracked even if the Operation is in an error state so that each
+// EndTransaction still closes the level its BeginTransaction opened.
+func (op *Operation) beginSavepoint() {
+	var sp = savepoint{name: fmt.Sprintf("magicsql_sp%d", len(op.savepoints)+1)}
+	if op.Err() == nil {
+		op.Exec(savepointSQL(op.dialect(), sp.name))
+		sp.created = op.Err() == nil
+	}
+	op.savepoints = append(op.savepoints, sp)
+}
+
+// endSavepoint closes the innermost savepoint, rolling back to it first if
+// rollback is true.  Rollbacks are sent even when the Operation has an error,
+// since that's exactly when they're needed.  A level whose SAVEPOINT was never
+// created is simply dropped.
+func (op *Operation) endSavepoint(rollback bool) {
+	var last = len(op.savepoints) - 1
+	var sp = op.savepoints[last]
+	op.savepoints = op.savepoints[:last]
+	if !sp.created {
+		return
+	}
+
+	var d = op.dialect()
+	var release = releaseSavepointSQL(d, sp.name)
+	if rollback {
+		op.tx.ExecContext(op.Context(), rollbackToSavepointSQL(d, sp.name))
+		if release != "" {
+			op.tx.ExecContext(op.Context(), release)
+		}
+		return
+	}
+
+	if release != "" {
+		op.Exec(release)
+	}
+}
+
+// Rollback tries to roll back the transaction even if there is no error.  In
+// a nested transaction, only the innermost savepoint is rolled back.
 func (op *Operation) Rollback() {
 	// If there was never a transaction due to errors, this could happen and we
 	// don't want a panic
@@ -196,13 +462,20 @@ func (op *Operation) Rollback() {
 		return
 	}
 
+	if len(op.savepoints) > 0 {
+		op.endSavepoint(true)
+		return
+	}
+
 	op.tx.Rollback()
 	op.tx = nil
+	op.txStmts = nil
 	op.q = op.parent.db
 }
 
 // EndTransaction commits the transaction if no errors occurred, or rolls back
-// if there was an error
+// if there was an error.  In a nested transaction, the innermost savepoint is
+// released or rolled back instead.
 func (op *Operation) EndTransaction() {
 	// If there was never a transaction due to errors, this could happen and we
 	// don't want a panic
@@ -210,22 +483,32 @@ func (op *Operation) EndTransaction() {
 		return
 	}
 
+	if len(op.savepoints) > 0 {
+		op.endSavepoint(op.Err() != nil)
+		return
+	}
+
 	if op.Err() != nil {
 		op.tx.Rollback()
 	} else {
-		op.SetErr(op.tx.Commit())
+		op.setErr(PhaseCommit, "", nil, op.tx.Commit())
 	}
 
 	op.tx = nil
+	op.txStmts = nil
 	op.q = op.parent.db
 }
 
 // Table creates an OperationTable tied to the given table name and reflecting
-// on obj's type to auto-build certain SQL statements
+// on obj's type to auto-build certain SQL statements.  If tableName is empty,
+// the table is named after obj's type using the DB's NamingStrategy.
 func (op *Operation) Table(tableName string, obj interface{}) *OperationTable {
-	var mt = &MagicTable{Object: obj, Name: tableName}
-	mt.Configure(nil)
-	return &OperationTable{op: op, t: mt}
+	var naming NamingStrategy
+	var tagKeys []string
+	if op.parent != nil {
+		naming, tagKeys = op.parent.namingConfig()
+	}
+	return &OperationTable{op: op, t: cachedTable(tableName, reflect.TypeOf(obj).Elem(), nil, naming, tagKeys)}
 }
 
 // OperationTable allows tying a stored MagicTable to this specific operation,
@@ -239,7 +522,7 @@ func (op *Operation) OperationTable(mt *MagicTable) *OperationTable {
 // zero.  Stores any errors the database returns, and fails if obj isn't tagged
 // with a primary key field.
 func (op *Operation) Save(tableName string, obj interface{}) *Result {
-	var emptyResult = &Result{nil, op}
+	var emptyResult = &Result{op: op}
 
 	if op.Err() != nil {
 		return emptyResult
@@ -254,6 +537,12 @@ func (op *Operation) Save(tableName string, obj interface{}) *Result {
 	return ot.Save(obj)
 }
 
+// Find wraps Operation.Table() and Table.Find().  It looks up a single
+// record by primary key.
+func (op *Operation) Find(tableName string, dest interface{}, id interface{}) (ok bool) {
+	return op.Table(tableName, dest).Find(dest, id)
+}
+
 // Select wraps Operation.Table() and Table.Select().  It creates a Select
 // object for further refining.
 func (op *Operation) Select(tableName string, obj interface{}) Select {
@@ -1,6 +1,7 @@
 package magicsql
 
 import (
+	"fmt"
 	"reflect"
 )
 
@@ -12,28 +13,87 @@ type OperationTable struct {
 }
 
 // Reconfigure sends explicit ConfigTags data to the underlying MagicTable in
-// order to override the reflected structure's tags
+// order to override the reflected structure's tags.  Tables created by
+// Operation.Table are shared, so they're swapped for the cached table matching
+// conf rather than modified.
 func (ot *OperationTable) Reconfigure(conf ConfigTags) {
+	if ot.t.statements != nil {
+		ot.t = cachedTable(ot.t.Name, ot.t.RType, conf, ot.t.Naming, ot.t.TagKeys)
+		return
+	}
 	ot.t.Configure(conf)
 }
 
+// dialect returns the Dialect used for generating this table's SQL: the
+// parent DB's if one was set, otherwise the MagicTable's
+func (ot *OperationTable) dialect() Dialect {
+	if ot.op.parent != nil {
+		if d := ot.op.parent.ownDialect(); d != nil {
+			return d
+		}
+	}
+	return ot.t.dialect()
+}
+
+// fieldConfig returns the settings for converting this table's fields.
+// Strict conversion is used if either the table or the parent DB asks for it.
+func (ot *OperationTable) fieldConfig() fieldConfig {
+	var conf = fieldConfig{strict: ot.t.Strict}
+	if ot.op.parent != nil {
+		conf = ot.op.parent.fieldConfig()
+		conf.strict = conf.strict || ot.t.Strict
+	}
+	return conf
+}
+
+// scanStruct wraps MagicTable.ScanStruct, using the settings of the table and
+// the DB
+func (ot *OperationTable) scanStruct(dest interface{}) []interface{} {
+	return ot.t.scanStruct(dest, ot.fieldConfig())
+}
+
 // Select simply instantiates a Select instance with the OperationTable set up
 // for it to use for gathering fields and running the query
 func (ot *OperationTable) Select() Select {
 	return NewSelect(ot)
 }
 
+// Find reads the record whose primary key is id into dest, returning false if
+// there's no such record.  Like Select.First, a missing record is only stored
+// as an error if the Operation has been told to treat it as one.  Stores an
+// error if the table has no primary key.
+func (ot *OperationTable) Find(dest interface{}, id interface{}) (ok bool) {
+	if ot.t.primaryKey == nil {
+		ot.op.SetErr(fmt.Errorf("no primary key tagged for structure %s", ot.t.RType.Name()))
+		return false
+	}
+
+	var d = ot.dialect()
+	var where = fmt.Sprintf("%s = %s", d.Quote(ot.t.primaryKey.Name), d.Placeholder(1))
+	return ot.Select().Where(where, id).First(dest)
+}
+
 // Save determines if an INSERT or UPDATE is necessary (primary key of 0 means
 // INSERT here), generates the SQL and arguments, and runs the Exec call on the
 // database.  Behavior may be unpredictable if a MagicTable was manually
 // registered with a structure of a different type than obj.
+//
+// After an insert, the primary key is set from the driver's LastInsertId.
+// Dialects implementing ReturningDialect (PostgreSQL and SQLServer, whose
+// drivers don't support LastInsertId) read the new key back from the INSERT
+// itself instead.
 func (ot *OperationTable) Save(obj interface{}) *Result {
 	// Check for object's primary key field being zero
 	var rVal = reflect.ValueOf(obj).Elem()
-	var pkValField = rVal.FieldByName(ot.t.primaryKey.Field.Name)
+	var pkValField, _ = ot.t.primaryKey.value(rVal, true)
 
 	if pkValField.Interface() == reflect.Zero(pkValField.Type()).Interface() {
-		var res = ot.op.Exec(ot.t.InsertSQL(), ot.t.InsertArgs(obj)...)
+		var d = ot.dialect()
+		if query := ot.t.insertReturningSQL(d); query != "" {
+			return ot.insertReturning(query, ot.t.insertArgs(obj, ot.fieldConfig()), pkValField)
+		}
+
+		var res = ot.op.exec(ot.t.insertSQL(d), ot.t.insertArgs(obj, ot.fieldConfig()), true)
 		switch pkValField.Type().Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			pkValField.SetInt(res.LastInsertId())
@@ -43,7 +103,29 @@ func (ot *OperationTable) Save(obj interface{}) *Result {
 		return res
 	}
 
-	return ot.op.Exec(ot.t.UpdateSQL(), ot.t.UpdateArgs(obj)...)
+	return ot.op.exec(ot.t.updateSQL(ot.dialect()), ot.t.updateArgs(obj, ot.fieldConfig()), true)
+}
+
+// insertReturning runs an INSERT which returns the new primary key, scanning
+// it into key.  The query always goes to the primary database, since it
+// writes data.
+func (ot *OperationTable) insertReturning(query string, args []interface{}, key reflect.Value) *Result {
+	var rows = ot.op.queryOn(ot.op.q, query, args, true)
+	defer rows.Close()
+
+	var rk returnedKey
+	if rows.Next() {
+		rows.Scan(key.Addr().Interface())
+		rk.rows = 1
+	}
+
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rk.id = key.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rk.id = int64(key.Uint())
+	}
+	return &Result{rk, ot.op, rows.query, rows.args}
 }
 
 // Insert forces an insert, ignoring any primary key tagging.  Note that
@@ -54,10 +136,5 @@ func (ot *OperationTable) Save(obj interface{}) *Result {
 // tagging for generating SQL and arg lists, instead relying on field and
 // column name mappings
 func (ot *OperationTable) Insert(obj interface{}) *Result {
-	var pkField = ot.t.primaryKey
-	ot.t.primaryKey = nil
-	var res = ot.op.Exec(ot.t.InsertSQL(), ot.t.InsertArgs(obj)...)
-	ot.t.primaryKey = pkField
-
-	return res
+	return ot.op.exec(ot.t.insertSQL(ot.dialect()), ot.t.insertArgs(obj, ot.fieldConfig()), true)
 }
@@ -0,0 +1,90 @@
+package magicsql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RecordedStatement is a single database call captured by a Recording.  Args
+// are rendered as SQL literals at the time of the call, so later changes to
+// the structures they came from don't alter the record.
+type RecordedStatement struct {
+	Kind QueryKind
+	SQL  string
+	Args []string
+}
+
+// Recording captures every statement run by a DB's Operations, in order, so
+// tests can lock down the exact SQL generated for their structures.  It's
+// safe for concurrent use, though the order of statements from concurrent
+// Operations is naturally unpredictable.
+type Recording struct {
+	mu         sync.Mutex
+	stopped    bool
+	statements []RecordedStatement
+
+	db   *DB
+	hook Hook
+}
+
+// Record starts a new Recording of every statement run by Operations created
+// after this call.  Recording continues until Stop is called.
+func (db *DB) Record() *Recording {
+	var r = &Recording{db: db}
+	r.hook = &HookFuncs{After: r.record}
+	db.AddHook(r.hook)
+	return r
+}
+
+func (r *Recording) record(ctx context.Context, e *QueryEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+
+	var args = make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = sqlLiteral(arg)
+	}
+	r.statements = append(r.statements, RecordedStatement{e.Kind, e.SQL, args})
+}
+
+// Stop ends the recording and removes its hook from the DB; statements run
+// afterward aren't captured, even by Operations which already had the hook
+func (r *Recording) Stop() {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+	r.db.RemoveHook(r.hook)
+}
+
+// Reset discards everything recorded so far
+func (r *Recording) Reset() {
+	r.mu.Lock()
+	r.statements = nil
+	r.mu.Unlock()
+}
+
+// Statements returns a copy of the statements recorded so far
+func (r *Recording) Statements() []RecordedStatement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedStatement(nil), r.statements...)
+}
+
+// Transcript returns the recorded statements as text suitable for a golden
+// file: each statement's kind and SQL on one line, followed by an indented
+// line of arguments if there were any
+func (r *Recording) Transcript() string {
+	var b strings.Builder
+	for _, st := range r.Statements() {
+		fmt.Fprintf(&b, "%s: %s\n", st.Kind, st.SQL)
+		if len(st.Args) > 0 {
+			fmt.Fprintf(&b, "  args: %s\n", strings.Join(st.Args, ", "))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,35 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func TestRecordingStop(t *testing.T) {
+	var db = getdb()
+	var values = HookFuncs{After: func(ctx context.Context, e *QueryEvent) {}}
+	var other = &HookFuncs{}
+	db.AddHook(values)
+	db.AddHook(other)
+
+	var rec = db.Record()
+	assert.Equal(3, len(db.hooks), "Recording adds a hook", t)
+	var op = db.Operation()
+	op.Save("foos", &Foo{ONE: "recorded"})
+	rec.Stop()
+	rec.Stop()
+
+	assert.Equal(2, len(db.hooks), "Stopped recording removes its hook", t)
+	assert.True(db.hooks[1] == Hook(other), "Other hooks are kept in order", t)
+
+	op.Save("foos", &Foo{ONE: "not recorded"})
+	assert.Equal(1, len(rec.Statements()), "Operations with the old hook list don't record after Stop", t)
+
+	db.RemoveHook(other)
+	db.RemoveHook(values)
+	assert.Equal(1, len(db.hooks), "Non-comparable hooks can't be removed", t)
+}
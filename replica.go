@@ -0,0 +1,86 @@
+package magicsql
+
+import (
+	"database/sql"
+	"sync/atomic"
+)
+
+// ReplicaStrategy determines how a DB picks a read replica
+type ReplicaStrategy int
+
+// Available replica strategies
+const (
+	// RoundRobin cycles through the replicas in the order they were added
+	RoundRobin ReplicaStrategy = iota
+
+	// LeastLoaded picks the replica with the fewest connections in use
+	LeastLoaded
+)
+
+// AddReplicas registers read replicas for the DB.  Once a DB has replicas,
+// Operation.Query and the Select terminal methods (First, AllObjects, etc.)
+// read from a replica unless the Operation is in a transaction or has been
+// told to use the primary.  Exec, Prepare, Save, and Insert always go to the
+// primary.
+//
+// Replicas should be added while setting up the DB, before any Operations are
+// created, as this isn't safe for concurrent use.
+func (db *DB) AddReplicas(replicas ...*sql.DB) {
+	db.replicas = append(db.replicas, replicas...)
+}
+
+// Replicas returns the read replicas registered with AddReplicas
+func (db *DB) Replicas() []*sql.DB {
+	return db.replicas
+}
+
+// SetReplicaStrategy sets how a replica is chosen for each read.  The default
+// is RoundRobin.
+func (db *DB) SetReplicaStrategy(s ReplicaStrategy) {
+	db.replicaStrategy = s
+}
+
+// replica returns the replica to use for the next read, or nil if there are
+// no replicas
+func (db *DB) replica() *sql.DB {
+	switch len(db.replicas) {
+	case 0:
+		return nil
+	case 1:
+		return db.replicas[0]
+	}
+
+	if db.replicaStrategy == LeastLoaded {
+		var best = db.replicas[0]
+		var bestInUse = best.Stats().InUse
+		for _, r := range db.replicas[1:] {
+			if inUse := r.Stats().InUse; inUse < bestInUse {
+				best, bestInUse = r, inUse
+			}
+		}
+		return best
+	}
+
+	var n = atomic.AddUint32(&db.nextReplica, 1)
+	return db.replicas[int(n-1)%len(db.replicas)]
+}
+
+// UsePrimary forces all further reads on this Operation to go to the primary
+// database instead of a replica.  This is useful for reading back data the
+// Operation just wrote, since replicas may lag behind the primary.
+func (op *Operation) UsePrimary() {
+	op.primary = true
+}
+
+// reader returns the Querier to use for reads: the transaction if there is
+// one, otherwise a replica unless the primary has been forced
+func (op *Operation) reader() Querier {
+	if op.tx != nil || op.primary || op.parent == nil {
+		return op.q
+	}
+
+	if r := op.parent.replica(); r != nil {
+		return r
+	}
+	return op.q
+}
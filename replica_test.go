@@ -0,0 +1,66 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func getReplica(one string) *sql.DB {
+	var r, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	r.SetMaxOpenConns(1)
+	_, err = r.Exec(`create table foos (one text, two INTEGER PRIMARY KEY, tree bool,
+		four int, four_point_five int, seven TEXT DEFAULT "blargh")`)
+	if err != nil {
+		panic(err)
+	}
+	r.Exec("INSERT INTO foos (one) VALUES (?)", one)
+	return r
+}
+
+func firstFooOne(op *Operation) string {
+	var foo = &Foo{}
+	op.Select("foos", &Foo{}).First(foo)
+	return foo.ONE
+}
+
+func TestReplicaRouting(t *testing.T) {
+	var db = getdb()
+	db.AddReplicas(getReplica("replica 1"), getReplica("replica 2"))
+
+	var op = db.Operation()
+	op.Save("foos", &Foo{ONE: "primary"})
+	assert.Equal("replica 1", firstFooOne(op), "First read goes to the first replica", t)
+	assert.Equal("replica 2", firstFooOne(op), "Second read goes to the second replica", t)
+	assert.Equal("replica 1", firstFooOne(op), "Third read cycles back to the first replica", t)
+
+	op.BeginTransaction()
+	assert.Equal("primary", firstFooOne(op), "Reads in a transaction use the primary", t)
+	op.EndTransaction()
+
+	op.UsePrimary()
+	assert.Equal("primary", firstFooOne(op), "UsePrimary forces reads to the primary", t)
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+}
+
+func TestReplicaLeastLoaded(t *testing.T) {
+	var db = getdb()
+	var busy = getReplica("busy")
+	db.AddReplicas(busy, getReplica("idle"))
+	db.SetReplicaStrategy(LeastLoaded)
+
+	assert.Equal("busy", firstFooOne(db.Operation()), "Ties go to the first replica", t)
+
+	// Hold a connection on the busy replica
+	var conn, _ = busy.Conn(context.Background())
+	assert.Equal("idle", firstFooOne(db.Operation()), "The replica with fewer connections in use is chosen", t)
+	conn.Close()
+}
@@ -6,36 +6,48 @@ import (
 
 // Result is a wrapper for sql.Result
 type Result struct {
-	r   sql.Result
-	err errorable
+	r     sql.Result
+	op    *Operation
+	query string
+	args  []interface{}
 }
 
 // Err returns the first error encountered on any operation the parent DB
 // object oversees
 func (r *Result) Err() error {
-	return r.err.Err()
+	return r.op.Err()
 }
 
 // LastInsertId returns the wrapped Result's LastInsertId() unless an error
 // has occurred, in which case 0 is returned
 func (r *Result) LastInsertId() int64 {
-	if r.err.Err() != nil {
+	if r.op.Err() != nil {
 		return 0
 	}
 
 	var i, err = r.r.LastInsertId()
-	r.err.SetErr(err)
+	r.op.setErr(PhaseExec, r.query, r.args, err)
 	return i
 }
 
 // RowsAffected returns the wrapped Result's RowsAffected() unless an error has
 // occurred, in which case 0 is returned
 func (r *Result) RowsAffected() int64 {
-	if r.err.Err() != nil {
+	if r.op.Err() != nil {
 		return 0
 	}
 
 	var i, err = r.r.RowsAffected()
-	r.err.SetErr(err)
+	r.op.setErr(PhaseExec, r.query, r.args, err)
 	return i
 }
+
+// returnedKey is the sql.Result for an INSERT whose key was read back from
+// the database rather than reported by the driver
+type returnedKey struct {
+	id   int64
+	rows int64
+}
+
+func (rk returnedKey) LastInsertId() (int64, error) { return rk.id, nil }
+func (rk returnedKey) RowsAffected() (int64, error) { return rk.rows, nil }
@@ -12,50 +12,61 @@ type Scannable interface {
 
 // Rows is a light wrapper for sql.Rows
 type Rows struct {
-	rows *sql.Rows
-	err  errorable
+	rows  *sql.Rows
+	op    *Operation
+	query string
+	args  []interface{}
 }
 
 // Err returns the first error encountered on any operation the parent DB
 // object oversees
 func (r *Rows) Err() error {
-	return r.err.Err()
+	return r.op.Err()
 }
 
-// Next wraps sql.Rows.Next().  If an error exists, false is returned.
+// Next wraps sql.Rows.Next().  If an error exists, false is returned.  When
+// there are no more rows, any error which stopped iteration (such as a
+// cancelled context) is stored.
 func (r *Rows) Next() bool {
-	if r.err.Err() != nil {
+	if r.op.Err() != nil {
 		return false
 	}
 
-	return r.rows.Next()
+	if r.rows.Next() {
+		return true
+	}
+
+	r.op.setErr(PhaseQuery, r.query, r.args, r.rows.Err())
+	return false
 }
 
 // Columns wraps sql.Rows.Columns().  If an error exists, nil is returned.
 func (r *Rows) Columns() []string {
-	if r.err.Err() != nil {
+	if r.op.Err() != nil {
 		return nil
 	}
 
 	var cols, err = r.rows.Columns()
-	r.err.SetErr(err)
+	r.op.setErr(PhaseQuery, r.query, r.args, err)
 	return cols
 }
 
 // Scan wraps sql.Rows.Scan()
 func (r *Rows) Scan(dest ...interface{}) {
-	if r.err.Err() != nil {
+	if r.op.Err() != nil {
 		return
 	}
 
-	r.err.SetErr(r.rows.Scan(dest...))
+	r.op.setErr(PhaseScan, r.query, r.args, r.rows.Scan(dest...))
 }
 
-// Close wraps sql.Rows.Close()
+// Close wraps sql.Rows.Close().  The underlying rows are closed even if the
+// Operation has an error, so a failure partway through iterating doesn't leak
+// the connection.
 func (r *Rows) Close() {
-	if r.err.Err() != nil {
+	if r.rows == nil {
 		return
 	}
 
-	r.err.SetErr(r.rows.Close())
+	r.op.setErr(PhaseQuery, r.query, r.args, r.rows.Close())
 }
@@ -3,7 +3,6 @@ package magicsql
 import (
 	"fmt"
 	"reflect"
-	"strings"
 )
 
 // Select defines the table, where clause, and potentially other elements of an
@@ -19,19 +18,16 @@ type Select struct {
 }
 
 func selectSQL(s Select) string {
-	var sql = fmt.Sprintf("SELECT %s FROM %s", strings.Join(s.ot.t.FieldNames(), ","), s.ot.t.Name)
+	var d = s.ot.dialect()
+	var ts = s.ot.t.sqlFor(d)
+	var sql = fmt.Sprintf("SELECT %s FROM %s", ts.columns, ts.name)
 	if s.where != "" {
 		sql += fmt.Sprintf(" WHERE %s", s.where)
 	}
 	if s.order != "" {
 		sql += fmt.Sprintf(" ORDER BY %s", s.order)
 	}
-	if s.limit > 0 {
-		sql += fmt.Sprintf(" LIMIT %d", s.limit)
-	}
-	if s.offset > 0 {
-		sql += fmt.Sprintf(" OFFSET %d", s.offset)
-	}
+	sql += d.LimitOffset(s.limit, s.offset, s.order != "")
 
 	return sql
 }
@@ -77,10 +73,10 @@ func (s Select) SQL() string {
 // OperationTable, and returns the resulting rows
 func (s Select) Query() *Rows {
 	if s.ot.op.Err() != nil {
-		return &Rows{nil, s.ot.op}
+		return &Rows{op: s.ot.op}
 	}
 
-	return s.ot.op.Query(s.SQL(), s.whereArgs...)
+	return s.ot.op.query(s.SQL(), s.whereArgs, true)
 }
 
 // EachRow wraps Query, yielding a Scannable per row to the callback instead of
@@ -96,16 +92,32 @@ func (s Select) EachRow(cb func(Scannable)) {
 
 // First builds the SQL statement, executes it through the parent
 // OperationTable, and returns the first object into dest.  If there are no
-// rows, ok is false.
+// rows, ok is false, and if the Operation has been told to treat a missing
+// row as an error (see Operation.SetNotFoundIsError), a NotFoundError is
+// stored as well.
 func (s Select) First(dest interface{}) (ok bool) {
+	return s.first(dest, s.ot.op.notFoundIsError)
+}
+
+// FirstOrErr works like First, but always stores a NotFoundError on the
+// Operation if there are no rows, so a chain of lookups only needs its error
+// checked once at the end
+func (s Select) FirstOrErr(dest interface{}) (ok bool) {
+	return s.first(dest, true)
+}
+
+func (s Select) first(dest interface{}, mustExist bool) bool {
 	var r = s.Query()
 	defer r.Close()
 
 	if !r.Next() {
+		if mustExist && s.ot.op.Err() == nil {
+			s.ot.op.SetErr(&NotFoundError{Table: s.ot.t.Name, Where: s.where, Args: s.ot.op.errArgs(s.whereArgs)})
+		}
 		return false
 	}
 
-	r.Scan(s.ot.t.ScanStruct(dest)...)
+	r.Scan(s.ot.scanStruct(dest)...)
 	return true
 }
 
@@ -119,7 +131,7 @@ func (s Select) AllObjects(ptr interface{}) {
 	var slice = reflect.ValueOf(ptr).Elem()
 	for rows.Next() {
 		var obj = reflect.New(s.ot.t.RType).Interface()
-		rows.Scan(s.ot.t.ScanStruct(obj)...)
+		rows.Scan(s.ot.scanStruct(obj)...)
 		slice.Set(reflect.Append(slice, reflect.ValueOf(obj)))
 	}
 }
@@ -131,7 +143,7 @@ func (s Select) EachObject(dest interface{}, cb func()) {
 	defer r.Close()
 
 	for r.Next() {
-		r.Scan(s.ot.t.ScanStruct(dest)...)
+		r.Scan(s.ot.scanStruct(dest)...)
 		cb()
 	}
 }
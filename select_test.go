@@ -3,6 +3,8 @@
 package magicsql
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
 
 	"github.com/Nerdmaster/magicsql/assert"
@@ -29,3 +31,52 @@ func TestSQL(t *testing.T) {
 	assert.Equal(expectedBase+" WHERE x = ? ORDER BY foo DESC LIMIT 10",
 		s5.SQL(), "SQL when there's an order clause mixed in", t)
 }
+
+func TestFirstOrErr(t *testing.T) {
+	var db = getdb()
+	db.DataSource().Exec("INSERT INTO foos (one, two) VALUES ('exists', 1)")
+
+	var op = db.Operation()
+	var foo = &Foo{}
+	assert.False(op.Select("foos", &Foo{}).Where("one = ?", "nope").First(foo), "First finds nothing", t)
+	assert.True(op.Err() == nil, "First doesn't store an error by default", t)
+
+	assert.True(op.Select("foos", &Foo{}).Where("one = ?", "exists").FirstOrErr(foo), "FirstOrErr finds the row", t)
+	assert.True(op.Err() == nil, "No error when the row exists", t)
+
+	op.Select("foos", &Foo{}).Where("one = ?", "nope").FirstOrErr(foo)
+	assert.True(errors.Is(op.Err(), ErrNotFound), "Missing row is ErrNotFound", t)
+	assert.True(errors.Is(op.Err(), sql.ErrNoRows), "Missing row is sql.ErrNoRows", t)
+	assert.Equal("magicsql: no rows found in foos where one = ?", op.Err().Error(), "Error message", t)
+}
+
+func TestFind(t *testing.T) {
+	var db = getdb()
+	db.DataSource().Exec("INSERT INTO foos (one, two) VALUES ('exists', 1)")
+
+	var op = db.Operation()
+	var foo = &Foo{}
+	assert.True(op.Find("foos", foo, 1), "Find by primary key", t)
+	assert.Equal("exists", foo.ONE, "Find read the record", t)
+
+	op.SetNotFoundIsError(true)
+	assert.False(op.Find("foos", foo, 2), "Find a missing record", t)
+	var nf *NotFoundError
+	assert.True(errors.As(op.Err(), &nf), "Operation-wide setting stores a NotFoundError", t)
+	assert.Equal("two = ?", nf.Where, "Where clause uses the primary key", t)
+	assert.Equal(2, nf.Args[0], "Args hold the id", t)
+
+	db.SetRedactArgs(true)
+	op = db.Operation()
+	var secret = "hunter2"
+	op.Select("foos", &Foo{}).Where("one = ?", &secret).FirstOrErr(foo)
+	assert.True(errors.As(op.Err(), &nf), "FirstOrErr stores a NotFoundError", t)
+	assert.Equal(RedactedArg, nf.Args[0], "Args are redacted", t)
+
+	db.SetRedactArgs(false)
+	op = db.Operation()
+	op.Select("foos", &Foo{}).Where("one = ?", &secret).FirstOrErr(foo)
+	secret = "changed"
+	errors.As(op.Err(), &nf)
+	assert.Equal("hunter2", nf.Args[0], "Args are snapshotted", t)
+}
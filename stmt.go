@@ -6,44 +6,58 @@ import (
 
 // Stmt is a light wrapper for sql.Stmt
 type Stmt struct {
-	st  *sql.Stmt
-	err errorable
+	st    *sql.Stmt
+	query string
+	op    *Operation
 }
 
 // Err returns the first error encountered on any operation the parent DB
 // object oversees
 func (s *Stmt) Err() error {
-	return s.err.Err()
+	return s.op.Err()
 }
 
-// Exec wraps sql.Stmt.Exec(), returning a wrapped Result
+// Exec wraps sql.Stmt.ExecContext(), returning a wrapped Result
 func (s *Stmt) Exec(args ...interface{}) *Result {
-	if s.err.Err() != nil {
-		return &Result{nil, s.err}
+	if s.op.checkContext(PhaseExec, s.query, args) != nil {
+		return &Result{op: s.op}
 	}
 
-	var r, err = s.st.Exec(args...)
-	s.err.SetErr(err)
-	return &Result{r, s.err}
+	var e = s.op.beforeQuery(KindExec, s.query, args)
+	if s.op.Err() != nil {
+		return &Result{op: s.op}
+	}
+
+	var r, err = s.st.ExecContext(s.op.Context(), e.Args...)
+	s.op.afterQuery(e, r, err)
+	s.op.setErr(PhaseExec, s.query, e.Args, err)
+	return &Result{r, s.op, s.query, e.Args}
 }
 
-// Query wraps sql.Stmt.Query(), returning a wrapped Rows
+// Query wraps sql.Stmt.QueryContext(), returning a wrapped Rows
 func (s *Stmt) Query(args ...interface{}) *Rows {
-	if s.err.Err() != nil {
-		return &Rows{nil, s.err}
+	if s.op.checkContext(PhaseQuery, s.query, args) != nil {
+		return &Rows{op: s.op}
+	}
+
+	var e = s.op.beforeQuery(KindQuery, s.query, args)
+	if s.op.Err() != nil {
+		return &Rows{op: s.op}
 	}
 
-	var r, err = s.st.Query(args...)
-	s.err.SetErr(err)
-	return &Rows{r, s.err}
+	var r, err = s.st.QueryContext(s.op.Context(), e.Args...)
+	s.op.afterQuery(e, nil, err)
+	s.op.setErr(PhaseQuery, s.query, e.Args, err)
+	return &Rows{r, s.op, s.query, e.Args}
 }
 
-// Close wraps sql.Stmt.Close()
+// Close wraps sql.Stmt.Close().  The statement is closed even if the
+// Operation has an error.
 func (s *Stmt) Close() {
-	if s.err.Err() != nil {
+	if s.st == nil {
 		return
 	}
 
 	var err = s.st.Close()
-	s.err.SetErr(err)
+	s.op.setErr(PhasePrepare, s.query, nil, err)
 }
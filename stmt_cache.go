@@ -0,0 +1,232 @@
+package magicsql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// StatementCacheStats reports how a DB's prepared statement cache is doing
+type StatementCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// stmtKey identifies a cached statement: the same SQL prepared on the primary
+// and on a replica are different statements
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+// stmtEntry is a cached statement.  refs counts the callers currently using
+// st; an evicted statement is only closed once nobody is using it, so a
+// statement is never closed between get and the call it was fetched for.
+type stmtEntry struct {
+	key     stmtKey
+	st      *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+// stmtCache is a concurrency-safe LRU cache of prepared statements.  Evicted
+// statements are closed once released by every caller using them;
+// database/sql keeps them alive until any rows they produced are closed.
+type stmtCache struct {
+	sync.Mutex
+	max   int
+	lru   *list.List
+	items map[stmtKey]*list.Element
+	stats StatementCacheStats
+}
+
+func newStmtCache(max int) *stmtCache {
+	return &stmtCache{max: max, lru: list.New(), items: make(map[stmtKey]*list.Element)}
+}
+
+// get returns the cached statement for query on db, preparing and caching it
+// if necessary.  The caller must call release once it's done with the
+// statement, and not use it afterward.
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, query string) (st *sql.Stmt, release func(), err error) {
+	var key = stmtKey{db, query}
+
+	c.Lock()
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		c.stats.Hits++
+		var e = c.acquire(el)
+		c.Unlock()
+		return e.st, func() { c.release(e) }, nil
+	}
+	c.stats.Misses++
+	c.Unlock()
+
+	// Prepare without holding the lock so one slow prepare doesn't block every
+	// other cached query
+	st, err = db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	// Another goroutine may have prepared the same statement in the meantime
+	if el, ok := c.items[key]; ok {
+		st.Close()
+		c.lru.MoveToFront(el)
+		var e = c.acquire(el)
+		return e.st, func() { c.release(e) }, nil
+	}
+
+	var e = &stmtEntry{key: key, st: st, refs: 1}
+	c.items[key] = c.lru.PushFront(e)
+	c.evict()
+	return st, func() { c.release(e) }, nil
+}
+
+// acquire takes a reference to el's statement.  The caller must hold the
+// lock.
+func (c *stmtCache) acquire(el *list.Element) *stmtEntry {
+	var e = el.Value.(*stmtEntry)
+	e.refs++
+	return e
+}
+
+// release drops a reference to e's statement, closing it if it was evicted
+// and this was the last reference
+func (c *stmtCache) release(e *stmtEntry) {
+	c.Lock()
+	e.refs--
+	var done = e.evicted && e.refs == 0
+	c.Unlock()
+
+	if done {
+		e.st.Close()
+	}
+}
+
+// evict removes the least recently used statements until the cache is within
+// its size limit, closing those nobody is using.  The caller must hold the
+// lock.
+func (c *stmtCache) evict() {
+	for c.lru.Len() > c.max {
+		var el = c.lru.Back()
+		var e = el.Value.(*stmtEntry)
+		c.lru.Remove(el)
+		delete(c.items, e.key)
+		e.evicted = true
+		if e.refs == 0 {
+			e.st.Close()
+		}
+		c.stats.Evictions++
+	}
+}
+
+// resize changes the cache's size limit, evicting statements if needed
+func (c *stmtCache) resize(max int) {
+	c.Lock()
+	c.max = max
+	c.evict()
+	c.Unlock()
+}
+
+func (c *stmtCache) snapshot() StatementCacheStats {
+	c.Lock()
+	defer c.Unlock()
+	var s = c.stats
+	s.Size = c.lru.Len()
+	return s
+}
+
+// SetStatementCacheSize enables the DB's prepared statement cache, which holds
+// up to size statements for the SQL generated by OperationTable.Save,
+// OperationTable.Insert, and the Select terminal methods.  A size of zero
+// closes all cached statements and disables the cache.  It's safe to call
+// while Operations are running; statements they're using are closed once
+// they're done with them.
+func (db *DB) SetStatementCacheSize(size int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.stmts == nil {
+		if size > 0 {
+			db.stmts = newStmtCache(size)
+		}
+		return
+	}
+
+	db.stmts.resize(size)
+	if size <= 0 {
+		db.stmts = nil
+	}
+}
+
+// StatementCacheStats returns hit, miss, and eviction counters for the DB's
+// prepared statement cache, along with its current size
+func (db *DB) StatementCacheStats() StatementCacheStats {
+	var c = db.stmtCache()
+	if c == nil {
+		return StatementCacheStats{}
+	}
+	return c.snapshot()
+}
+
+// stmtCache returns the DB's statement cache, or nil if it's disabled
+func (db *DB) stmtCache() *stmtCache {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.stmts
+}
+
+// noRelease is the release function for statements which aren't shared
+func noRelease() {}
+
+// cachedStmt returns the prepared statement to use for query when sent through
+// q, or nil if the DB has no statement cache.  release must be called once
+// the statement's Exec or Query call returns.  Inside a transaction, the
+// cached statement is rebound to the transaction once and reused for the rest
+// of it.
+func (op *Operation) cachedStmt(q Querier, query string) (st *sql.Stmt, release func(), err error) {
+	if op.parent == nil {
+		return nil, noRelease, nil
+	}
+	var c = op.parent.stmtCache()
+	if c == nil {
+		return nil, noRelease, nil
+	}
+
+	if op.tx == nil {
+		var db, ok = q.(*sql.DB)
+		if !ok {
+			return nil, noRelease, nil
+		}
+		st, release, err = c.get(op.Context(), db, query)
+		if err != nil {
+			return nil, noRelease, err
+		}
+		return st, release, nil
+	}
+
+	if st, ok := op.txStmts[query]; ok {
+		return st, noRelease, nil
+	}
+
+	st, release, err = c.get(op.Context(), op.parent.db, query)
+	if err != nil {
+		return nil, noRelease, err
+	}
+
+	// The transaction's statement keeps the cached one alive until the
+	// transaction ends, so the reference can be dropped right away
+	st = op.tx.StmtContext(op.Context(), st)
+	release()
+	if op.txStmts == nil {
+		op.txStmts = make(map[string]*sql.Stmt)
+	}
+	op.txStmts[query] = st
+	return st, noRelease, nil
+}
@@ -0,0 +1,113 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func TestStatementCache(t *testing.T) {
+	var db = getdb()
+	db.SetStatementCacheSize(2)
+
+	var op = db.Operation()
+	for i := 0; i < 5; i++ {
+		op.Save("foos", &Foo{ONE: fmt.Sprintf("foo %d", i)})
+	}
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	var stats = db.StatementCacheStats()
+	assert.Equal(uint64(1), stats.Misses, "The insert was prepared once", t)
+	assert.Equal(uint64(4), stats.Hits, "The insert was reused for the other saves", t)
+	assert.Equal(1, stats.Size, "One statement is cached", t)
+
+	op.BeginTransaction()
+	var foo = &Foo{ONE: "updated", TwO: 1}
+	op.Save("foos", foo)
+	foo.ONE = "updated again"
+	op.Save("foos", foo)
+	op.EndTransaction()
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+
+	stats = db.StatementCacheStats()
+	assert.Equal(uint64(2), stats.Misses, "The update was prepared once", t)
+	assert.Equal(uint64(4), stats.Hits, "The transaction reused its own statement for the second update", t)
+
+	var fooList []*Foo
+	op.Select("foos", &Foo{}).Where("two = ?", 1).AllObjects(&fooList)
+	assert.Equal("updated again", fooList[0].ONE, "Cached update statement was committed", t)
+
+	stats = db.StatementCacheStats()
+	assert.Equal(uint64(1), stats.Evictions, "The select pushed the insert out of the cache", t)
+	assert.Equal(2, stats.Size, "Cache is at its limit", t)
+
+	db.SetStatementCacheSize(0)
+	assert.Equal(0, db.StatementCacheStats().Size, "Disabling the cache empties it", t)
+	op.Save("foos", &Foo{ONE: "uncached"})
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+}
+
+func TestStatementCacheEvictInUse(t *testing.T) {
+	var db = getdb()
+	var c = newStmtCache(1)
+	var ctx = context.Background()
+
+	var st, release, err = c.get(ctx, db.DataSource(), "SELECT COUNT(*) FROM foos")
+	assert.True(err == nil, fmt.Sprintf("Prepare error (%s) is nil", err), t)
+	var _, release2, _ = c.get(ctx, db.DataSource(), "SELECT COUNT(*) FROM foos WHERE two > 0")
+	release2()
+	assert.Equal(uint64(1), c.snapshot().Evictions, "First statement was evicted", t)
+
+	var n int
+	err = st.QueryRowContext(ctx).Scan(&n)
+	assert.True(err == nil, fmt.Sprintf("Evicted statement in use still works (%s)", err), t)
+
+	release()
+	err = st.QueryRowContext(ctx).Scan(&n)
+	assert.True(err != nil, "Evicted statement is closed once released", t)
+}
+
+func TestStatementCacheConcurrency(t *testing.T) {
+	var db = getdb()
+	db.SetStatementCacheSize(2)
+	db.Operation().Save("foos", &Foo{ONE: "one"})
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, 32)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				var op = db.Operation()
+				var fooList []*Foo
+				op.Select("foos", &Foo{}).Where(fmt.Sprintf("two > %d", (g+i)%8-8)).AllObjects(&fooList)
+				if op.Err() != nil {
+					errs <- op.Err()
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			db.SetStatementCacheSize(i%3 + 1)
+			db.StatementCacheStats()
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent cached query failed: %s", err)
+	}
+	assert.True(db.StatementCacheStats().Evictions > 0, "Statements were evicted under load", t)
+}
@@ -0,0 +1,165 @@
+package magicsql
+
+import (
+	"container/list"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tableKey identifies a cached MagicTable: the structure's type, the table
+// name, the ConfigTags used in place of struct tags (if any), and the naming
+// strategy and tag keys
+type tableKey struct {
+	rtype   reflect.Type
+	name    string
+	tagged  bool
+	conf    string
+	naming  NamingStrategy
+	tagKeys string
+}
+
+// maxCachedTables bounds the shared table cache, so programs which build
+// ConfigTags or naming strategies on the fly don't grow it forever
+const maxCachedTables = 1024
+
+// tables holds the MagicTables built through Operations, keyed by tableKey,
+// so the reflection in Configure only happens once per combination
+var tables = newTableCache(maxCachedTables)
+
+type tableEntry struct {
+	key tableKey
+	t   *MagicTable
+}
+
+// tableCache is a concurrency-safe LRU cache of shared MagicTables.  Evicted
+// tables are simply forgotten; since shared tables are never modified, anyone
+// still holding one can keep using it.
+type tableCache struct {
+	sync.Mutex
+	max   int
+	lru   *list.List
+	items map[tableKey]*list.Element
+}
+
+func newTableCache(max int) *tableCache {
+	return &tableCache{max: max, lru: list.New(), items: make(map[tableKey]*list.Element)}
+}
+
+// get returns the table cached under key, calling build to create and cache
+// it if necessary
+func (c *tableCache) get(key tableKey, build func() *MagicTable) *MagicTable {
+	c.Lock()
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		c.Unlock()
+		return el.Value.(*tableEntry).t
+	}
+	c.Unlock()
+
+	// Build without holding the lock; Configure's reflection isn't cheap
+	var t = build()
+
+	c.Lock()
+	defer c.Unlock()
+
+	// Another goroutine may have built the same table in the meantime
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*tableEntry).t
+	}
+
+	c.items[key] = c.lru.PushFront(&tableEntry{key, t})
+	for c.lru.Len() > c.max {
+		var el = c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.items, el.Value.(*tableEntry).key)
+	}
+	return t
+}
+
+// len returns the number of cached tables
+func (c *tableCache) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.lru.Len()
+}
+
+// confKey flattens ConfigTags into a string usable as part of a map key
+func confKey(conf ConfigTags) string {
+	var pairs = make([]string, 0, len(conf))
+	for field, tag := range conf {
+		pairs = append(pairs, field+"\x00"+tag)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x01")
+}
+
+// cachedTable returns the shared MagicTable for the given name and structure
+// type, configured with conf, naming, and tagKeys, building and caching it on
+// first use.  Shared tables must never be reconfigured or otherwise modified,
+// as any number of Operations may be using them at once.
+//
+// A naming strategy which can't be used as a map key (such as a NamingFuncs
+// value) means the table is built fresh on every call.
+func cachedTable(name string, rtype reflect.Type, conf ConfigTags, naming NamingStrategy, tagKeys []string) *MagicTable {
+	var build = func() *MagicTable {
+		var t = &MagicTable{Name: name, Object: reflect.New(rtype).Interface(), Naming: naming, TagKeys: tagKeys}
+		t.Configure(conf)
+		t.statements = &sync.Map{}
+		return t
+	}
+
+	if naming != nil && !reflect.ValueOf(naming).Comparable() {
+		return build()
+	}
+
+	var key = tableKey{
+		rtype:   rtype,
+		name:    name,
+		tagged:  conf == nil,
+		conf:    confKey(conf),
+		naming:  naming,
+		tagKeys: strings.Join(tagKeys, ","),
+	}
+	return tables.get(key, build)
+}
+
+// tableSQL holds the SQL fragments and statements generated for a table in a
+// single dialect
+type tableSQL struct {
+	name            string
+	columns         string
+	insert          string
+	insertReturning string
+	update          string
+}
+
+// sqlFor returns the table's SQL for dialect d.  Shared tables keep the
+// results so they're only generated once per dialect; tables built by hand
+// can be changed at any time, so their SQL is generated on every call.
+func (t *MagicTable) sqlFor(d Dialect) *tableSQL {
+	var cacheable = t.statements != nil && reflect.TypeOf(d).Comparable()
+	if cacheable {
+		if s, ok := t.statements.Load(d); ok {
+			return s.(*tableSQL)
+		}
+	}
+
+	var columns = make([]string, len(t.sqlFields))
+	for i, bf := range t.sqlFields {
+		columns[i] = d.Quote(bf.Name)
+	}
+
+	var s = &tableSQL{
+		name:    quoteName(d, t.Name),
+		columns: strings.Join(columns, ","),
+		update:  t.buildUpdateSQL(d),
+	}
+	s.insert, s.insertReturning = t.buildInsertSQL(d)
+	if cacheable {
+		t.statements.Store(d, s)
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func TestTableCache(t *testing.T) {
+	var op = &Operation{}
+	var a = op.Table("foos", &Foo{})
+	var b = op.Table("foos", &Foo{})
+	assert.True(a.t == b.t, "Operations share one MagicTable per type and name", t)
+	assert.True(a.t != op.Table("other_foos", &Foo{}).t, "Table name is part of the key", t)
+
+	var conf = ConfigTags{"ONE": "uno,primary"}
+	var c = op.Table("foos", &Foo{})
+	c.Reconfigure(conf)
+	assert.True(c.t != a.t, "Reconfigure swaps in a different table", t)
+	assert.Equal("UPDATE foos SET tw_o = ?,three = ?,four = ?,four_point_five = ?,five = ?,seven = ? WHERE uno = ?",
+		c.t.UpdateSQL(), "Reconfigured table uses the new tags", t)
+	assert.Equal("UPDATE foos SET one = ?,tree = ?,four = ?,four_point_five = ? WHERE two = ?",
+		a.t.UpdateSQL(), "Reconfiguring didn't touch the shared table", t)
+
+	var d = op.Table("foos", &Foo{})
+	d.Reconfigure(ConfigTags{"ONE": "uno,primary"})
+	assert.True(c.t == d.t, "Equal ConfigTags share a table", t)
+}
+
+func TestTableCacheBounded(t *testing.T) {
+	var saved = tables
+	tables = newTableCache(2)
+	defer func() { tables = saved }()
+
+	var ot = (&Operation{}).Table("foos", &Foo{})
+	var first = ot.t
+	for i := 0; i < 5; i++ {
+		ot.Reconfigure(ConfigTags{"ONE": fmt.Sprintf("uno%d", i)})
+	}
+	assert.Equal(2, tables.len(), "Per-call ConfigTags don't grow the cache past its limit", t)
+	assert.True((&Operation{}).Table("foos", &Foo{}).t != first, "Least recently used table was evicted", t)
+	assert.Equal("one", first.sqlFields[0].Name, "Evicted table is still usable", t)
+}
+
+func TestTableCacheSQL(t *testing.T) {
+	var ot = (&Operation{}).Table("foos", &Foo{})
+	assert.True(ot.t.sqlFor(Generic) == ot.t.sqlFor(Generic), "SQL is generated once per dialect", t)
+	assert.Equal(`INSERT INTO "foos" ("one","tree","four","four_point_five") VALUES ($1,$2,$3,$4)`,
+		ot.t.insertSQL(PostgreSQL), "Each dialect gets its own SQL", t)
+
+	var mt = Table("foos", &Foo{})
+	assert.True(mt.sqlFields[0] == ot.t.sqlFields[0], "Table reuses cached field bindings", t)
+	mt.Name = "bars"
+	assert.Equal("INSERT INTO bars (one,tree,four,four_point_five) VALUES (?,?,?,?)", mt.InsertSQL(),
+		"SQL for a table built by hand follows its changes", t)
+}
+
+func TestTableCacheConcurrency(t *testing.T) {
+	var db = getdb()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var op = db.Operation()
+			var foo = &Foo{}
+			op.Select("foos", &Foo{}).First(foo)
+			_ = op.Select("foos", &Foo{}).Count().SQL()
+		}()
+	}
+	wg.Wait()
+}
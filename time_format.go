@@ -0,0 +1,214 @@
+package magicsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayouts are the layouts tried, in order, when a time.Time field
+// is read from a column the driver returns as text, unless the DB has been
+// given other layouts
+var DefaultTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// TimeConfig controls how a DB's Operations read and write time.Time fields
+type TimeConfig struct {
+	// Layouts are tried in order when a time column comes back from the
+	// driver as text.  If empty, DefaultTimeLayouts is used.
+	Layouts []string
+
+	// Location is the time zone times are stored in.  Times are converted to
+	// it when written, text without a zone is parsed in it, and times read
+	// back are converted to it.  If nil, times are written as-is, zoneless
+	// text is parsed as UTC (or as local time for fields with a "date" or
+	// "layout=" tag, so they read back as written), and times parsed from
+	// text are converted to the local time zone.
+	Location *time.Location
+}
+
+// SetTimeConfig sets how time.Time fields are read and written by this DB's
+// Operations.  Individual fields can override this with tag options:
+// "unix" or "unixms" store the time as an integer count of seconds or
+// milliseconds since the Unix epoch, "layout=..." stores it as text in the
+// given layout, and "date" is shorthand for "layout=2006-01-02".
+func (db *DB) SetTimeConfig(tc TimeConfig) {
+	db.mu.Lock()
+	db.timeConfig = &tc
+	db.mu.Unlock()
+}
+
+// timeFormat combines a DB's TimeConfig with a field's own time options
+type timeFormat struct {
+	unit    time.Duration
+	layout  string
+	layouts []string
+	loc     *time.Location
+}
+
+// newTimeFormat returns the format for a field with the given tag options,
+// or nil if neither the field nor tc change anything from the defaults
+func newTimeFormat(tc *TimeConfig, unit time.Duration, layout string) *timeFormat {
+	if tc == nil && unit == 0 && layout == "" {
+		return nil
+	}
+
+	var tf = &timeFormat{unit: unit, layout: layout, layouts: DefaultTimeLayouts}
+	if tc != nil {
+		tf.loc = tc.Location
+		if len(tc.Layouts) > 0 {
+			tf.layouts = tc.Layouts
+		}
+	}
+	if layout != "" {
+		tf.layouts = []string{layout}
+	}
+	return tf
+}
+
+// parse reads s as a time using the format's layouts
+func (tf *timeFormat) parse(s string) (time.Time, error) {
+	if tf.unit != 0 {
+		var n, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return tf.fromEpoch(n), nil
+	}
+
+	// A field's own layout is written in the time's own zone, so without a
+	// storage zone, zoneless text has to be read as local time to come back
+	// as the same date and clock time.  Otherwise a date would move back a
+	// day on every save west of UTC.
+	var loc = tf.loc
+	if loc == nil && tf.layout != "" {
+		loc = time.Local
+	}
+
+	var t time.Time
+	var err error
+	for _, layout := range tf.layouts {
+		if loc != nil {
+			t, err = time.ParseInLocation(layout, s, loc)
+		} else {
+			t, err = time.Parse(layout, s)
+		}
+		if err == nil {
+			return tf.local(t, true), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseValue is parse, but its errors also wrap ErrInvalidValue
+func (tf *timeFormat) parseValue(s string) (time.Time, error) {
+	var t, err = tf.parse(s)
+	if err != nil {
+		return t, fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+	return t, nil
+}
+
+// fromEpoch converts a count of the format's units since the epoch
+func (tf *timeFormat) fromEpoch(n int64) time.Time {
+	var t time.Time
+	if tf.unit == time.Millisecond {
+		t = time.UnixMilli(n)
+	} else {
+		t = time.Unix(n, 0)
+	}
+	return tf.local(t, true)
+}
+
+// local converts a time read from the database to the storage zone.  Without
+// one, parsed times are converted to the local zone and times from the driver
+// are left alone.
+func (tf *timeFormat) local(t time.Time, parsed bool) time.Time {
+	switch {
+	case tf.loc != nil:
+		return t.In(tf.loc)
+	case parsed:
+		return t.Local()
+	}
+	return t
+}
+
+// value converts t for storage
+func (tf *timeFormat) value(t time.Time) driver.Value {
+	if tf.loc != nil {
+		t = t.In(tf.loc)
+	}
+
+	switch {
+	case tf.unit == time.Millisecond:
+		return t.UnixMilli()
+	case tf.unit != 0:
+		return t.Unix()
+	case tf.layout != "":
+		return t.Format(tf.layout)
+	}
+	return t
+}
+
+// timeArg is the Exec argument for a time.Time field with a non-default
+// format
+type timeArg struct {
+	t  time.Time
+	tf *timeFormat
+}
+
+// Value implements driver.Valuer
+func (a timeArg) Value() (driver.Value, error) {
+	return a.tf.value(a.t), nil
+}
+
+// defaultTimeFormat is used when a NullableField has no format of its own
+var defaultTimeFormat = newTimeFormat(&TimeConfig{}, 0, "")
+
+// storeTime stores src into a time.Time field.  Text which can't be parsed,
+// and any other value which can't be a time (such as an integer in a field
+// not tagged "unix" or "unixms"), is always an error, since silently storing
+// the zero time loses data.  The error always wraps ErrInvalidValue, along
+// with the parse error for text which couldn't be parsed.
+func (nf *NullableField) storeTime(d reflect.Value, src interface{}) error {
+	var tf = nf.timeFormat
+	if tf == nil {
+		tf = defaultTimeFormat
+	}
+
+	var t time.Time
+	var err error
+	switch v := src.(type) {
+	case nil:
+		return nf.fail(src, ErrUnexpectedNull)
+	case time.Time:
+		t = tf.local(v, false)
+	case int64:
+		if tf.unit == 0 {
+			err = ErrInvalidValue
+			break
+		}
+		t = tf.fromEpoch(v)
+	case string:
+		t, err = tf.parseValue(v)
+	case []byte:
+		t, err = tf.parseValue(string(v))
+	default:
+		err = ErrInvalidValue
+	}
+
+	if err != nil {
+		return &ConversionError{Column: nf.Column, Field: nf.Field, Value: src, Err: err}
+	}
+	d.Set(reflect.ValueOf(t))
+	return nil
+}
@@ -0,0 +1,141 @@
+package magicsql
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetryPolicy controls how DB.Transaction retries a transaction which failed
+// due to a deadlock, a busy database, or a serialization failure.  The whole
+// closure is re-run on each attempt, so it must be safe to repeat.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times the closure may run.  Values
+	// below 2 disable retries.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry.  It doubles after each
+	// failed attempt.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.  Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Retryable decides whether a failed attempt should be retried.  If nil,
+	// IsRetryable is used.
+	Retryable func(error) bool
+}
+
+// retryable reports whether err should cause another attempt
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// delay returns how long to wait before the given retry, where the first
+// retry is 1
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	var d = p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// IsRetryable returns true if err is classified as a deadlock, a busy
+// database, or a serialization failure
+func IsRetryable(err error) bool {
+	var c = Classify(err)
+	if c == nil {
+		return false
+	}
+
+	switch c.Kind {
+	case ErrDeadlock, ErrBusy, ErrSerializationFailure:
+		return true
+	}
+	return false
+}
+
+// SetRetryPolicy tells DB.Transaction how to retry transactions which fail
+// with a retryable error.  A nil policy (the default) means no retries.
+func (db *DB) SetRetryPolicy(p *RetryPolicy) {
+	db.mu.Lock()
+	db.retry = p
+	db.mu.Unlock()
+}
+
+// Transaction runs fn inside a transaction on a new Operation.  If fn returns
+// nil and the Operation has no error, the transaction is committed; otherwise
+// it is rolled back.  If fn panics, the transaction is rolled back and the
+// panic continues up the stack.
+//
+// When the DB has a RetryPolicy, a transaction which fails with a retryable
+// error is re-run from the beginning on a fresh Operation.  The final
+// attempt's error is returned.
+func (db *DB) Transaction(fn func(op *Operation) error) error {
+	return db.TransactionContext(context.Background(), fn)
+}
+
+// TransactionContext works like Transaction, but every attempt's Operation
+// uses ctx, and cancelling ctx stops any further retries
+func (db *DB) TransactionContext(ctx context.Context, fn func(op *Operation) error) error {
+	db.mu.RLock()
+	var p = db.retry
+	db.mu.RUnlock()
+	for attempt := 1; ; attempt++ {
+		var err = db.runTransaction(ctx, fn)
+		if err == nil || p == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+
+		var timer = time.NewTimer(p.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// runTransaction runs a single attempt of a closure-based transaction
+func (db *DB) runTransaction(ctx context.Context, fn func(op *Operation) error) error {
+	var op = db.OperationContext(ctx)
+	op.BeginTransaction()
+	defer func() {
+		if r := recover(); r != nil {
+			op.abortTransaction()
+			panic(r)
+		}
+	}()
+
+	if op.Err() == nil {
+		op.SetErr(fn(op))
+	}
+
+	if op.TransactionDepth() > 1 {
+		op.SetErr(fmt.Errorf("transaction closure left %d nested transactions open", op.TransactionDepth()-1))
+		op.abortTransaction()
+		return op.Err()
+	}
+
+	op.EndTransaction()
+	return op.Err()
+}
+
+// abortTransaction rolls back the whole transaction, including any open
+// savepoints
+func (op *Operation) abortTransaction() {
+	if op.tx == nil {
+		return
+	}
+
+	op.savepoints = nil
+	op.Rollback()
+}
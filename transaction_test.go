@@ -0,0 +1,189 @@
+// NOTE: This file relies on Foo from the magic table test
+
+package magicsql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Nerdmaster/magicsql/assert"
+)
+
+func countFoos(db *DB) uint64 {
+	return db.Operation().Select("foos", &Foo{}).Count().RowCount()
+}
+
+func TestNestedTransactionCommit(t *testing.T) {
+	var db = getdb()
+	var op = db.Operation()
+
+	op.BeginTransaction()
+	op.Save("foos", &Foo{ONE: "outer"})
+	op.BeginTransaction()
+	assert.Equal(2, op.TransactionDepth(), "Nested transaction depth", t)
+	op.Save("foos", &Foo{ONE: "inner"})
+	op.EndTransaction()
+	assert.Equal(1, op.TransactionDepth(), "Depth after the inner transaction ends", t)
+	op.EndTransaction()
+
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(0, op.TransactionDepth(), "No transaction after the outer transaction ends", t)
+	assert.Equal(uint64(2), countFoos(db), "Both rows were committed", t)
+}
+
+func TestNestedTransactionRollback(t *testing.T) {
+	var db = getdb()
+	var op = db.Operation()
+	var innerErr = errors.New("inner failure")
+
+	op.BeginTransaction()
+	op.Save("foos", &Foo{ONE: "outer"})
+	op.BeginTransaction()
+	op.Save("foos", &Foo{ONE: "inner"})
+	op.SetErr(innerErr)
+	op.EndTransaction()
+
+	assert.Equal(innerErr, op.Err(), "Inner error is kept after rolling back to the savepoint", t)
+	op.Reset()
+	op.Save("foos", &Foo{ONE: "outer again"})
+	op.EndTransaction()
+
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	var fooList []*Foo
+	db.Operation().Select("foos", &Foo{}).Order("two").AllObjects(&fooList)
+	assert.Equal(2, len(fooList), "Only the outer rows survived", t)
+	assert.Equal("outer", fooList[0].ONE, "First outer row", t)
+	assert.Equal("outer again", fooList[1].ONE, "Second outer row", t)
+}
+
+func TestNestedTransactionOuterRollback(t *testing.T) {
+	var db = getdb()
+	var op = db.Operation()
+
+	op.BeginTransaction()
+	op.Save("foos", &Foo{ONE: "outer"})
+	op.BeginTransaction()
+	op.Save("foos", &Foo{ONE: "inner"})
+	op.SetErr(errors.New("inner failure"))
+	op.EndTransaction()
+	op.EndTransaction()
+
+	assert.Equal(uint64(0), countFoos(db), "Without a Reset, everything rolls back", t)
+}
+
+func TestNestedTransactionAfterError(t *testing.T) {
+	var db = getdb()
+	var op = db.Operation()
+
+	op.BeginTransaction()
+	op.BeginTransaction()
+	assert.True(op.savepoints[0].created, "Savepoint is created without an error", t)
+	op.SetErr(errors.New("failure"))
+	op.BeginTransaction()
+	assert.Equal(3, op.TransactionDepth(), "Level is tracked despite the error", t)
+	assert.False(op.savepoints[1].created, "No savepoint is created after an error", t)
+	op.EndTransaction()
+	assert.Equal(2, op.TransactionDepth(), "Uncreated level is closed", t)
+	op.EndTransaction()
+
+	op.Reset()
+	op.Save("foos", &Foo{ONE: "outer"})
+	op.EndTransaction()
+	assert.True(op.Err() == nil, fmt.Sprintf("Operation error (%s) is nil", op.Err()), t)
+	assert.Equal(uint64(1), countFoos(db), "Outer transaction still commits", t)
+}
+
+func TestTransactionOptions(t *testing.T) {
+	var db = getdb()
+	var readOnly = &sql.TxOptions{ReadOnly: true}
+	db.SetTxOptions(readOnly)
+
+	var op = db.Operation()
+	assert.Equal(readOnly, op.txOpts, "Operation inherits the DB's transaction options", t)
+	op.BeginTransaction()
+	op.BeginTransaction()
+	assert.True(op.Err() == nil, "Nested BeginTransaction doesn't apply default options", t)
+	op.EndTransaction()
+	op.EndTransaction()
+
+	op = db.Operation()
+	op.BeginTransactionWith(&sql.TxOptions{Isolation: sql.LevelSerializable})
+	op.Save("foos", &Foo{ONE: "serializable"})
+	op.BeginTransactionWith(&sql.TxOptions{ReadOnly: true})
+	assert.True(op.Err() != nil, "Nested transactions can't take options", t)
+	op.EndTransaction()
+	op.EndTransaction()
+	assert.Equal(0, op.TransactionDepth(), "All transaction levels were closed", t)
+	assert.Equal(uint64(0), countFoos(db), "Nothing was committed", t)
+}
+
+func TestTransactionClosure(t *testing.T) {
+	var db = getdb()
+
+	var err = db.Transaction(func(op *Operation) error {
+		op.Save("foos", &Foo{ONE: "committed"})
+		return nil
+	})
+	assert.True(err == nil, fmt.Sprintf("Transaction error (%s) is nil", err), t)
+	assert.Equal(uint64(1), countFoos(db), "Successful closure commits", t)
+
+	var failure = errors.New("nope")
+	err = db.Transaction(func(op *Operation) error {
+		op.Save("foos", &Foo{ONE: "rolled back"})
+		return failure
+	})
+	assert.Equal(failure, err, "Closure's error is returned", t)
+	assert.Equal(uint64(1), countFoos(db), "Failed closure rolls back", t)
+}
+
+func TestTransactionClosurePanic(t *testing.T) {
+	var db = getdb()
+	var recovered interface{}
+
+	func() {
+		defer func() { recovered = recover() }()
+		db.Transaction(func(op *Operation) error {
+			op.Save("foos", &Foo{ONE: "rolled back"})
+			panic("boom")
+		})
+	}()
+
+	assert.Equal("boom", recovered, "Panic is re-raised", t)
+	assert.Equal(uint64(0), countFoos(db), "Panicking closure rolls back", t)
+}
+
+func TestTransactionRetry(t *testing.T) {
+	var db = getdb()
+	db.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	var attempts int
+	var err = db.Transaction(func(op *Operation) error {
+		attempts++
+		op.Save("foos", &Foo{ONE: fmt.Sprintf("attempt %d", attempts)})
+		if attempts < 2 {
+			return errors.New("database is locked")
+		}
+		return nil
+	})
+	assert.True(err == nil, fmt.Sprintf("Transaction error (%s) is nil", err), t)
+	assert.Equal(2, attempts, "Busy database was retried once", t)
+	assert.Equal(uint64(1), countFoos(db), "Only the successful attempt was committed", t)
+
+	attempts = 0
+	err = db.Transaction(func(op *Operation) error {
+		attempts++
+		return errors.New("deadlock detected")
+	})
+	assert.Equal("deadlock detected", err.Error(), "Last attempt's error is returned", t)
+	assert.Equal(3, attempts, "Retries stop at MaxAttempts", t)
+
+	attempts = 0
+	db.Transaction(func(op *Operation) error {
+		attempts++
+		return errors.New("not retryable")
+	})
+	assert.Equal(1, attempts, "Other errors aren't retried", t)
+}